@@ -1,6 +1,9 @@
 package uphold
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // AccountService works with Account API endpoint
 type AccountService struct {
@@ -9,7 +12,7 @@ type AccountService struct {
 
 // ListAll accounts for a user
 func (a *AccountService) ListAll() (*[]Account, *Response, error) {
-	req, err := a.client.NewRequest("GET", "me/accounts", nil)
+	req, err := a.client.newRequest(OperationAccountListAll, "", "GET", "me/accounts", nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -27,7 +30,7 @@ func (a *AccountService) ListAll() (*[]Account, *Response, error) {
 func (a *AccountService) List(ID string) (*Account, *Response, error) {
 	rel := fmt.Sprintf("me/accounts/%s", ID)
 
-	req, err := a.client.NewRequest("GET", rel, nil)
+	req, err := a.client.newRequest(OperationAccountList, "", "GET", rel, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -40,3 +43,134 @@ func (a *AccountService) List(ID string) (*Account, *Response, error) {
 
 	return account, resp, nil
 }
+
+// bankCurrencies are the currencies of the bank account networks
+var bankCurrencies = map[AccountType]CurrencyCode{
+	AccountTypeSepa: CurrencyEUR,
+	AccountACH:      CurrencyUSD,
+}
+
+// BankAccountError is returned when a bank account
+// cannot be used for a deposit or a withdrawal
+type BankAccountError struct {
+	Account Account
+	Reason  string
+}
+
+// Error returns the string representation of the error
+func (e BankAccountError) Error() string {
+	return fmt.Sprintf("uphold: bank account %s cannot be used: %s", e.Account.ID, e.Reason)
+}
+
+// checkBankAccount checks that the account is a linked bank account
+// ready for transfers, holding the currency of its network, and returns
+// that currency
+func checkBankAccount(account Account) (CurrencyCode, error) {
+	currency, ok := bankCurrencies[account.Type]
+	if !ok {
+		return "", BankAccountError{account, fmt.Sprintf("type %q is not a bank account", account.Type)}
+	}
+	if account.Status != AccountStatusOK {
+		return "", BankAccountError{account, fmt.Sprintf("status is %q", account.Status)}
+	}
+	if account.Currency == "" {
+		return "", BankAccountError{account, "currency is unknown"}
+	}
+	if CurrencyCode(account.Currency) != currency {
+		return "", BankAccountError{account, fmt.Sprintf("%s accounts hold %s, not %s", account.Type, currency, account.Currency)}
+	}
+	return currency, nil
+}
+
+// Deposit quotes a deposit of amount, in the currency of the account,
+// from a linked bank account to card. The deposit is committed at once
+// if commit is set. The account is expected to come from List or ListAll,
+// a BankAccountError is returned if it is not a SEPA or ACH account ready
+// for transfers.
+func (a *AccountService) Deposit(from Account, to Card, amount float32, commit bool) (*Txn, *Response, error) {
+	currency, err := checkBankAccount(from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	q := Quote{
+		Denomination: &QuoteDenomination{Amount: amount, Currency: currency},
+		Origin:       from.ID,
+		Realtime:     commit,
+	}
+	return a.client.Transaction.createAs(to, q, OperationDeposit)
+}
+
+// Withdraw quotes a withdrawal of amount, in the currency of the
+// account, from card to a linked bank account. The withdrawal is
+// committed at once if commit is set. As with Deposit, a
+// BankAccountError is returned if the account cannot be used.
+func (a *AccountService) Withdraw(from Card, to Account, amount float32, commit bool) (*Txn, *Response, error) {
+	currency, err := checkBankAccount(to)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	q := Quote{
+		Denomination: &QuoteDenomination{Amount: amount, Currency: currency},
+		Destination:  to.ID,
+		Realtime:     commit,
+	}
+	return a.client.Transaction.createAs(from, q, OperationWithdraw)
+}
+
+// Business days usually taken by the bank account networks to settle
+var settlementDays = map[AccountType]int{
+	AccountTypeSepa: 1,
+	AccountACH:      3,
+}
+
+// Settlement tells when a bank transfer is expected to reach its destination
+type Settlement struct {
+	Network AccountType
+	Status  TxnStatus
+
+	// Settled is set once the transaction is completed
+	Settled bool
+
+	// Estimate is when the funds should be available, counted in the
+	// usual business days of the network from the creation of the
+	// transaction. It is only an estimate, the API does not tell. It
+	// is zero if the transaction is completed, failed or cancelled, or
+	// if its creation time is unknown.
+	Estimate time.Time
+}
+
+// ExpectedSettlement returns the settlement of a transaction made with
+// Deposit or Withdraw to or from account. The network is taken from the
+// origin or destination of the transaction when the API gives it.
+func ExpectedSettlement(txn Txn, account Account) Settlement {
+	s := Settlement{
+		Network: account.Type,
+		Status:  txn.Status,
+		Settled: txn.Status == TxnStatusCompleted,
+	}
+	if _, ok := bankCurrencies[AccountType(txn.Origin.Type)]; ok {
+		s.Network = AccountType(txn.Origin.Type)
+	} else if _, ok := bankCurrencies[AccountType(txn.Destination.Type)]; ok {
+		s.Network = AccountType(txn.Destination.Type)
+	}
+
+	switch txn.Status {
+	case TxnStatusCompleted, TxnStatusCancelled, TxnStatusFailed:
+		return s
+	}
+	if txn.CreatedAt == nil {
+		return s
+	}
+
+	t := *txn.CreatedAt
+	for days := settlementDays[s.Network]; days > 0; {
+		t = t.AddDate(0, 0, 1)
+		if t.Weekday() != time.Saturday && t.Weekday() != time.Sunday {
+			days--
+		}
+	}
+	s.Estimate = t
+	return s
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestAccountListAll(t *testing.T) {
@@ -71,3 +72,108 @@ func TestAccountList(t *testing.T) {
 		t.Errorf("Account.List() returned %+v, want %+v", accounts, want)
 	}
 }
+
+func TestAccountDeposit(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testFormValues(t, r, values{"commit": "true"})
+		testBody(t, r, `{"denomination":{"amount":"100","currency":"EUR"},"origin":"bank1"}`)
+		fmt.Fprint(w, `{"id":"t1","status":"processing","createdAt":"2016-10-14T10:00:00Z","origin":{"type":"sepa"}}`)
+	})
+
+	client.SetGrantedScopes([]Permission{PermissionTransactionsDeposit})
+	bank := Account{ID: "bank1", Currency: "EUR", Status: AccountStatusOK, Type: AccountTypeSepa}
+
+	txn, _, err := client.Account.Deposit(bank, Card{ID: "c1"}, 100, true)
+	if err != nil {
+		t.Fatalf("Account.Deposit() returned unexpected error: %v", err)
+	}
+
+	s := ExpectedSettlement(*txn, bank)
+	want := time.Date(2016, 10, 17, 10, 0, 0, 0, time.UTC) // the next business day after a Friday
+	if s.Settled || !s.Estimate.Equal(want) || s.Network != AccountTypeSepa {
+		t.Errorf("ExpectedSettlement() returned %+v, want settlement on %v", s, want)
+	}
+}
+
+func TestExpectedSettlementFinished(t *testing.T) {
+	created := time.Date(2016, 10, 14, 10, 0, 0, 0, time.UTC)
+	bank := Account{ID: "bank1", Currency: "USD", Status: AccountStatusOK, Type: AccountACH}
+
+	for _, status := range []TxnStatus{TxnStatusCompleted, TxnStatusCancelled, TxnStatusFailed} {
+		s := ExpectedSettlement(Txn{Status: status, CreatedAt: &created}, bank)
+		if !s.Estimate.IsZero() || s.Settled != (status == TxnStatusCompleted) {
+			t.Errorf("ExpectedSettlement() of a %s transaction returned %+v, want no estimate", status, s)
+		}
+	}
+
+	// the network of the transaction wins over the account given
+	s := ExpectedSettlement(Txn{Status: TxnStatusPending, CreatedAt: &created, Origin: Origin{Type: AccountTypeSepa}}, bank)
+	if want := time.Date(2016, 10, 17, 10, 0, 0, 0, time.UTC); s.Network != AccountTypeSepa || !s.Estimate.Equal(want) {
+		t.Errorf("ExpectedSettlement() returned %+v, want a SEPA settlement on %v", s, want)
+	}
+}
+
+func TestAccountDepositPolicy(t *testing.T) {
+	setup()
+	defer teardown()
+
+	origin := "sepa"
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"id":"t1","status":"pending","origin":{"type":"%s","amount":"500","currency":"EUR"}}`, origin)
+	})
+
+	client.SetPolicy(&Policy{MaxAmount: map[CurrencyCode]float32{CurrencyEUR: 100}})
+	bank := Account{ID: "bank1", Currency: "EUR", Status: AccountStatusOK, Type: AccountTypeSepa}
+
+	if _, _, err := client.Account.Deposit(bank, Card{ID: "c1"}, 500, false); err != nil {
+		t.Errorf("Account.Deposit() from a bank account returned unexpected error: %v", err)
+	}
+
+	// the API tells the origin is not a bank account
+	origin = "card"
+	_, _, err := client.Account.Deposit(bank, Card{ID: "c1"}, 500, false)
+	if v, ok := err.(PolicyViolationError); !ok || v.Rule != RuleMaxAmount {
+		t.Errorf("Account.Deposit() from a card returned %v, want a max amount violation", err)
+	}
+}
+
+func TestAccountWithdraw(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testBody(t, r, `{"denomination":{"amount":"50","currency":"USD"},"destination":"bank2"}`)
+		fmt.Fprint(w, `{"id":"t2","status":"pending"}`)
+	})
+
+	client.SetGrantedScopes([]Permission{PermissionTransactionsWithdraw})
+	bank := Account{ID: "bank2", Currency: "USD", Status: AccountStatusOK, Type: AccountACH}
+
+	if _, _, err := client.Account.Withdraw(Card{ID: "c1"}, bank, 50, false); err != nil {
+		t.Errorf("Account.Withdraw() returned unexpected error: %v", err)
+	}
+}
+
+func TestAccountTransferInvalidAccount(t *testing.T) {
+	setup()
+	defer teardown()
+
+	tests := []Account{
+		{ID: "a1", Currency: "USD", Status: AccountStatusOK, Type: AccountTypeCard},
+		{ID: "a2", Currency: "EUR", Status: AccountStatusFailed, Type: AccountTypeSepa},
+		{ID: "a3", Currency: "EUR", Status: AccountStatusOK, Type: AccountACH},
+		{ID: "a4", Status: AccountStatusOK, Type: AccountACH},
+	}
+
+	for _, a := range tests {
+		_, _, err := client.Account.Withdraw(Card{ID: "c1"}, a, 10, false)
+		if _, ok := err.(BankAccountError); !ok {
+			t.Errorf("Account.Withdraw() to %+v returned %v, want a BankAccountError", a, err)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package uphold
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Network is a blockchain funds can be withdrawn to
+type Network string
+
+// String implements Stringer interface
+func (n Network) String() string {
+	return string(n)
+}
+
+// Supported networks
+const (
+	NetworkBitcoin  Network = "bitcoin"
+	NetworkEthereum Network = "ethereum"
+	NetworkXRP      Network = "xrp-ledger"
+)
+
+// AddressError is returned when an address or a memo
+// is not valid on its network
+type AddressError struct {
+	Network Network
+	Address string
+	Reason  string
+}
+
+// Error returns the string representation of the error
+func (e AddressError) Error() string {
+	return fmt.Sprintf("uphold: invalid %s address %q: %s", e.Network, e.Address, e.Reason)
+}
+
+// ValidateAddress checks the format and the checksum of an address
+// without contacting the network. Bitcoin addresses are base58 (P2PKH
+// and P2SH) or bech32 and bech32m (segwit), for the main network or
+// the test network. Ethereum addresses in mixed case must have a valid
+// EIP-55 checksum. XRP addresses are classic base58 addresses.
+func ValidateAddress(network Network, address string) error {
+	var reason string
+	switch network {
+	case NetworkBitcoin:
+		reason = checkBitcoinAddress(address)
+	case NetworkEthereum:
+		reason = checkEthereumAddress(address)
+	case NetworkXRP:
+		reason = checkXRPAddress(address)
+	default:
+		reason = "unsupported network"
+	}
+
+	if reason != "" {
+		return AddressError{network, address, reason}
+	}
+	return nil
+}
+
+// ValidateMemo checks the memo or destination tag sent along with a
+// withdrawal. XRP destination tags are 32 bits unsigned integers, the
+// other networks take no memo.
+func ValidateMemo(network Network, memo string) error {
+	if memo == "" {
+		return nil
+	}
+	if network == NetworkXRP {
+		if _, err := strconv.ParseUint(memo, 10, 32); err != nil {
+			return AddressError{network, memo, "destination tag must be a number below 2^32"}
+		}
+		return nil
+	}
+	return AddressError{network, memo, "network takes no memo"}
+}
+
+// Version bytes of the bitcoin base58 addresses
+var bitcoinVersions = map[byte]bool{
+	0x00: true, // P2PKH
+	0x05: true, // P2SH
+	0x6f: true, // testnet P2PKH
+	0xc4: true, // testnet P2SH
+}
+
+// checkBitcoinAddress returns why the address is not valid, if it is not
+func checkBitcoinAddress(address string) string {
+	lower := strings.ToLower(address)
+	if strings.HasPrefix(lower, "bc1") || strings.HasPrefix(lower, "tb1") {
+		return checkSegwitAddress(address)
+	}
+
+	payload, reason := base58CheckDecode(address, bitcoinAlphabet)
+	if reason != "" {
+		return reason
+	}
+	if len(payload) != 21 || !bitcoinVersions[payload[0]] {
+		return "unknown address version"
+	}
+	return ""
+}
+
+// checkXRPAddress returns why the address is not valid, if it is not
+func checkXRPAddress(address string) string {
+	if !strings.HasPrefix(address, "r") {
+		return "address must start with r"
+	}
+
+	payload, reason := base58CheckDecode(address, rippleAlphabet)
+	if reason != "" {
+		return reason
+	}
+	if len(payload) != 21 || payload[0] != 0x00 {
+		return "unknown address version"
+	}
+	return ""
+}
+
+// ethereumAddressPattern matches ethereum addresses, checksum aside
+var ethereumAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// checkEthereumAddress returns why the address is not valid, if it is not.
+// Addresses all in lower or upper case carry no checksum.
+func checkEthereumAddress(address string) string {
+	if !ethereumAddressPattern.MatchString(address) {
+		return "address must be 0x followed by 40 hexadecimal digits"
+	}
+
+	hex := address[2:]
+	if hex == strings.ToLower(hex) || hex == strings.ToUpper(hex) {
+		return ""
+	}
+
+	hash := keccak256([]byte(strings.ToLower(hex)))
+	for i, c := range hex {
+		if c >= '0' && c <= '9' {
+			continue
+		}
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if upper := c >= 'A' && c <= 'F'; upper != (nibble >= 8) {
+			return "invalid EIP-55 checksum"
+		}
+	}
+	return ""
+}
+
+// Base58 alphabets
+const (
+	bitcoinAlphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	rippleAlphabet  = "rpshnaf39wBUDNEGHJKLM4PQRST7VWXYZ2bcdeCg65jkm8oFqi1tuvAxyz"
+)
+
+// base58CheckDecode decodes s and checks its trailing four bytes
+// checksum, returning the payload or why s is not valid
+func base58CheckDecode(s, alphabet string) ([]byte, string) {
+	b, ok := base58Decode(s, alphabet)
+	if !ok {
+		return nil, "invalid base58 character"
+	}
+	if len(b) < 5 {
+		return nil, "address too short"
+	}
+
+	payload, checksum := b[:len(b)-4], b[len(b)-4:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		return nil, "invalid checksum"
+	}
+	return payload, ""
+}
+
+// base58Decode decodes s written with alphabet
+func base58Decode(s, alphabet string) ([]byte, bool) {
+	var out []byte
+	for _, c := range s {
+		carry := strings.IndexRune(alphabet, c)
+		if carry < 0 {
+			return nil, false
+		}
+		for i := len(out) - 1; i >= 0; i-- {
+			carry += 58 * int(out[i])
+			out[i] = byte(carry)
+			carry >>= 8
+		}
+		for ; carry > 0; carry >>= 8 {
+			out = append([]byte{byte(carry)}, out...)
+		}
+	}
+
+	// leading zeros are written as the first character of the alphabet
+	for i := 0; i < len(s) && s[i] == alphabet[0]; i++ {
+		out = append([]byte{0}, out...)
+	}
+	return out, true
+}
+
+// Checksum constants of the bech32 encodings
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// checkSegwitAddress returns why the segwit address is not valid, if it is not
+func checkSegwitAddress(address string) string {
+	if address != strings.ToLower(address) && address != strings.ToUpper(address) {
+		return "mixed case"
+	}
+	address = strings.ToLower(address)
+
+	sep := strings.LastIndex(address, "1")
+	if len(address) > 90 || sep < 1 || sep+7 > len(address) {
+		return "invalid bech32 length"
+	}
+
+	hrp := address[:sep]
+	if hrp != "bc" && hrp != "tb" {
+		return "unknown human readable part"
+	}
+
+	data := make([]byte, 0, len(address)-sep-1)
+	for _, c := range address[sep+1:] {
+		v := strings.IndexRune(bech32Charset, c)
+		if v < 0 {
+			return "invalid bech32 character"
+		}
+		data = append(data, byte(v))
+	}
+
+	encoding := bech32Polymod(append(bech32ExpandHRP(hrp), data...))
+	if encoding != bech32Const && encoding != bech32mConst {
+		return "invalid checksum"
+	}
+
+	data = data[:len(data)-6]
+	if len(data) == 0 {
+		return "missing witness version"
+	}
+	version := data[0]
+	program, ok := convertBits(data[1:], 5, 8)
+	switch {
+	case version > 16:
+		return "invalid witness version"
+	case !ok || len(program) < 2 || len(program) > 40:
+		return "invalid witness program"
+	case version == 0 && len(program) != 20 && len(program) != 32:
+		return "invalid witness program length"
+	case version == 0 && encoding != bech32Const:
+		return "witness version 0 must use bech32"
+	case version != 0 && encoding != bech32mConst:
+		return "witness version 1 and above must use bech32m"
+	}
+	return ""
+}
+
+// bech32Polymod computes the bech32 checksum of values
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32ExpandHRP expands the human readable part for the checksum
+func bech32ExpandHRP(hrp string) []byte {
+	out := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+// convertBits regroups data from groups of from bits to groups of to
+// bits, without padding. It fails if the padding left is not zeros.
+func convertBits(data []byte, from, to uint) ([]byte, bool) {
+	var acc, bits uint
+	var out []byte
+	max := uint(1)<<to - 1
+
+	for _, v := range data {
+		acc = acc<<from | uint(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&max))
+		}
+	}
+	if bits >= from || (acc<<(to-bits))&max != 0 {
+		return nil, false
+	}
+	return out, true
+}
@@ -0,0 +1,89 @@
+package uphold
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestKeccak256(t *testing.T) {
+	tests := map[string]string{
+		"":    "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+		"abc": "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45",
+	}
+	for in, want := range tests {
+		sum := keccak256([]byte(in))
+		if got := hex.EncodeToString(sum[:]); got != want {
+			t.Errorf("keccak256(%q) returned %s, want %s", in, got, want)
+		}
+	}
+
+}
+
+func TestValidateAddress(t *testing.T) {
+	valid := []struct {
+		network Network
+		address string
+	}{
+		{NetworkBitcoin, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"},
+		{NetworkBitcoin, "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"},
+		{NetworkBitcoin, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{NetworkBitcoin, "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4"},
+		{NetworkBitcoin, "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{NetworkBitcoin, "tb1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3q0sl5k7"},
+		{NetworkEthereum, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{NetworkEthereum, "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"},
+		{NetworkEthereum, "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB"},
+		{NetworkEthereum, "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb"},
+		{NetworkEthereum, "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"},
+		{NetworkXRP, "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh"},
+		{NetworkXRP, "rrrrrrrrrrrrrrrrrrrrrhoLvTp"},
+	}
+	for _, tt := range valid {
+		if err := ValidateAddress(tt.network, tt.address); err != nil {
+			t.Errorf("ValidateAddress(%s, %s) returned unexpected error: %v", tt.network, tt.address, err)
+		}
+	}
+
+	invalid := []struct {
+		network Network
+		address string
+	}{
+		{NetworkBitcoin, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3"},
+		{NetworkBitcoin, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN0"},
+		{NetworkBitcoin, "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5"},
+		{NetworkBitcoin, "bc1qw508d6qejxtdg4y5r3zarvaRY0c5xw7kv8f3t4"},
+		{NetworkBitcoin, "bc1zw508d6qejxtdg4y5r3zarvaryvqyzf3du"},
+		{NetworkBitcoin, "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{NetworkBitcoin, "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh"},
+		{NetworkEthereum, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD"},
+		{NetworkEthereum, "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA"},
+		{NetworkEthereum, "5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{NetworkXRP, "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTi"},
+		{NetworkXRP, "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"},
+		{"dogecoin", "DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L"},
+	}
+	for _, tt := range invalid {
+		err := ValidateAddress(tt.network, tt.address)
+		if _, ok := err.(AddressError); !ok {
+			t.Errorf("ValidateAddress(%s, %s) returned %v, want an AddressError", tt.network, tt.address, err)
+		}
+	}
+}
+
+func TestValidateMemo(t *testing.T) {
+	if err := ValidateMemo(NetworkXRP, "4294967295"); err != nil {
+		t.Errorf("ValidateMemo() returned unexpected error: %v", err)
+	}
+	for _, tt := range []struct {
+		network Network
+		memo    string
+	}{
+		{NetworkXRP, "4294967296"},
+		{NetworkXRP, "abc"},
+		{NetworkBitcoin, "123"},
+	} {
+		if err := ValidateMemo(tt.network, tt.memo); err == nil {
+			t.Errorf("ValidateMemo(%s, %s) should return an error", tt.network, tt.memo)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package uphold
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// clientCredentialsSource obtains application tokens
+// with the OAuth client credentials grant
+type clientCredentialsSource struct {
+	http     *http.Client
+	cred     Credential
+	tokenURL string
+	scopes   []Permission
+}
+
+// ClientCredentialsTokenSource returns a TokenSource which obtains tokens
+// for the application itself from tokenURL using the OAuth client
+// credentials grant. Tokens are reused until they expire.
+func ClientCredentialsTokenSource(c Credential, tokenURL string, s []Permission) oauth2.TokenSource {
+	src := &clientCredentialsSource{
+		http:     http.DefaultClient,
+		cred:     c,
+		tokenURL: tokenURL,
+		scopes:   s,
+	}
+	return oauth2.ReuseTokenSource(nil, src)
+}
+
+// Token requests a new application token
+func (s *clientCredentialsSource) Token() (*oauth2.Token, error) {
+	v := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.scopes) > 0 {
+		v.Set("scope", strings.Join(PermissionsToSlice(s.scopes), " "))
+	}
+
+	return retrieveToken(s.http, s.cred, s.tokenURL, v)
+}
+
+// retrieveToken requests a token from the token endpoint,
+// authenticating with the application credentials
+func retrieveToken(hc *http.Client, cred Credential, tokenURL string, v url.Values) (*oauth2.Token, error) {
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", defaultContentType)
+	req.SetBasicAuth(cred.ClientID, cred.ClientSecret)
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if c := resp.StatusCode; c < 200 || c > 299 {
+		return nil, fmt.Errorf("uphold: cannot fetch token: %s\nResponse: %s", resp.Status, body)
+	}
+
+	var t struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		Scope        string `json:"scope"`
+	}
+	if err := json.Unmarshal(body, &t); err != nil {
+		return nil, fmt.Errorf("uphold: cannot decode token: %s", err)
+	}
+	if t.AccessToken == "" {
+		return nil, fmt.Errorf("uphold: server response missing access_token")
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+	}
+	if t.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+
+	return token.WithExtra(map[string]interface{}{"scope": t.Scope}), nil
+}
+
+// applicationOperations are sent with the application token when the
+// client has application credentials, every other request keeps the
+// authentication of the user
+var applicationOperations = map[Operation]bool{
+	OperationTransferApplication: true,
+}
+
+// applicationAuth holds the application credentials of a client
+type applicationAuth struct {
+	src    oauth2.TokenSource
+	scopes []Permission
+
+	// txns are the pending transactions created by the application,
+	// committed and cancelled with the application token too
+	mu   sync.Mutex
+	txns map[string]bool
+}
+
+// SetApplicationCredentials authenticates the operations on the account of
+// the application owner, such as transfers made with
+// TransactionService.CreateForApplication, with an application token
+// obtained from the token endpoint of the current environment using the
+// client credentials grant. Other requests keep the authentication of the
+// user. Call UseSandbox or UseLive before to select the environment.
+func (c *Client) SetApplicationCredentials(cred Credential, s []Permission) {
+	// the token is obtained with the transport of the client
+	src := &clientCredentialsSource{
+		http:     withoutOAuth(c.http),
+		cred:     cred,
+		tokenURL: c.tokenAccessURL.String(),
+		scopes:   s,
+	}
+	c.application = &applicationAuth{
+		src:    oauth2.ReuseTokenSource(nil, src),
+		scopes: s,
+		txns:   map[string]bool{},
+	}
+}
+
+// NewApplicationClient returns a client authenticated as the application
+// itself for application owned operations such as transfers requiring
+// PermissionTransactionsTransferApplication. Set the authentication of the
+// user, for instance with SetPersonalAccessToken, for other operations. The
+// sandbox environment is used if sandbox is true.
+func NewApplicationClient(cred Credential, s []Permission, sandbox bool) *Client {
+	c := NewClient(nil)
+	if sandbox {
+		c.UseSandbox()
+	}
+
+	c.SetApplicationCredentials(cred, s)
+	return c
+}
+
+// isApplicationOperation reports whether requests checked against
+// scopeOp are sent with the application token
+func (c *Client) isApplicationOperation(scopeOp Operation) bool {
+	return c.application != nil && applicationOperations[scopeOp]
+}
+
+// authorizeApplication replaces the authentication
+// of req with the application token
+func (c *Client) authorizeApplication(req *http.Request) (*http.Request, error) {
+	t, err := c.application.src.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	t.SetAuthHeader(req)
+	return withOwnAuth(req), nil
+}
+
+// trackApplicationTxn remembers, or forgets if done is set, a pending
+// transaction created by the application
+func (c *Client) trackApplicationTxn(id string, done bool) {
+	if c.application == nil || id == "" {
+		return
+	}
+
+	c.application.mu.Lock()
+	defer c.application.mu.Unlock()
+
+	if done {
+		delete(c.application.txns, id)
+	} else {
+		c.application.txns[id] = true
+	}
+}
+
+// applicationTxnOperation returns OperationTransferApplication for the
+// pending transactions created by the application, and op otherwise
+func (c *Client) applicationTxnOperation(txn Txn, op Operation) Operation {
+	if c.application == nil {
+		return op
+	}
+
+	c.application.mu.Lock()
+	defer c.application.mu.Unlock()
+
+	if c.application.txns[txn.ID] {
+		return OperationTransferApplication
+	}
+	return op
+}
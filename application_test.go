@@ -0,0 +1,112 @@
+package uphold
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSetApplicationCredentials(t *testing.T) {
+	setup()
+	defer teardown()
+
+	tokens := 0
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testFormValues(t, r, values{
+			"grant_type": "client_credentials",
+			"scope":      "cards:read transactions:transfer:application",
+		})
+		if id, secret, ok := r.BasicAuth(); !ok || id != "id" || secret != "secret" {
+			t.Errorf("Token request basic auth is %v:%v, want id:secret", id, secret)
+		}
+
+		tokens++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "app-token", "token_type": "bearer", "expires_in": 3600, "scope": "cards:read"}`)
+	})
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "Authorization", "Bearer user-token")
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "Authorization", "Bearer app-token")
+		fmt.Fprint(w, `{"id":"t1","status":"pending"}`)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/t1/commit", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "Authorization", "Bearer app-token")
+		fmt.Fprint(w, `{"id":"t1","status":"completed"}`)
+	})
+
+	client.SetPersonalAccessToken("user-token")
+	client.SetApplicationCredentials(Credential{ClientID: "id", ClientSecret: "secret"}, []Permission{
+		PermissionCardsRead,
+		PermissionTransactionsTransferApplication,
+	})
+
+	if _, _, err := client.Card.ListAll(); err != nil {
+		t.Fatalf("Card.ListAll() returned unexpected error: %v", err)
+	}
+
+	card := Card{ID: "c1"}
+	q := Quote{Denomination: &QuoteDenomination{Amount: 1, Currency: "USD"}, Destination: "jane@example.com"}
+	for i := 0; i < 2; i++ {
+		txn, _, err := client.Transaction.CreateForApplication(card, q)
+		if err != nil {
+			t.Fatalf("Transaction.CreateForApplication() returned unexpected error: %v", err)
+		}
+		if _, _, err := client.Transaction.Commit(card, *txn, ""); err != nil {
+			t.Fatalf("Transaction.Commit() returned unexpected error: %v", err)
+		}
+	}
+	if tokens != 1 {
+		t.Errorf("requested %d application tokens, want 1", tokens)
+	}
+}
+
+func TestSetApplicationCredentialsScopes(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.SetApplicationCredentials(Credential{ClientID: "id", ClientSecret: "secret"}, []Permission{PermissionCardsRead})
+
+	q := Quote{Denomination: &QuoteDenomination{Amount: 1, Currency: "USD"}, Destination: "jane@example.com"}
+	_, _, err := client.Transaction.CreateForApplication(Card{ID: "c1"}, q)
+	if _, ok := err.(MissingScopeError); !ok {
+		t.Errorf("Transaction.CreateForApplication() returned %v, want a MissingScopeError", err)
+	}
+}
+
+func TestSetApplicationCredentialsError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+	})
+
+	client.SetApplicationCredentials(Credential{ClientID: "id", ClientSecret: "wrong"}, nil)
+	if _, err := client.newRequest(OperationTransactionCreate, OperationTransferApplication, "POST", "me/cards/c1/transactions", nil); err == nil {
+		t.Error("Expected error when the application token cannot be obtained")
+	}
+}
+
+func TestNewApplicationClient(t *testing.T) {
+	c := NewApplicationClient(Credential{ClientID: "id"}, nil, true)
+
+	if got, want := c.apiURL.String(), SandboxAPIURL; got != want {
+		t.Errorf("API URL is %v, want %v", got, want)
+	}
+	if got, want := c.tokenAccessURL.String(), SandboxTokenAccessURL; got != want {
+		t.Errorf("token URL is %v, want %v", got, want)
+	}
+
+	c.UseLive()
+	term := c.Terminals("http://localhost/callback")
+	if got, want := term.AuthURL, LiveAuthURL; got != want {
+		t.Errorf("Terminals().AuthURL is %v, want %v", got, want)
+	}
+	if got, want := term.TokenURL, TokenAccessURL; got != want {
+		t.Errorf("Terminals().TokenURL is %v, want %v", got, want)
+	}
+}
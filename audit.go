@@ -0,0 +1,350 @@
+package uphold
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord is an entry of the audit trail of the transactions.
+// Records are chained: each one holds the hash of the previous one,
+// so that a removed or modified record breaks the chain.
+type AuditRecord struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor,omitempty"`
+	RequestID string    `json:"requestId,omitempty"`
+	Operation Operation `json:"operation"`
+
+	Card        string `json:"card"`
+	Quote       *Quote `json:"quote,omitempty"`
+	Transaction string `json:"transaction,omitempty"`
+	Destination string `json:"destination,omitempty"`
+
+	Result    *Txn   `json:"result,omitempty"`
+	Simulated bool   `json:"simulated,omitempty"`
+	Error     string `json:"error,omitempty"`
+
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash"`
+}
+
+// hash returns the hash of the record, its Hash excluded
+func (r AuditRecord) hash() (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditSink stores the audit records
+type AuditSink interface {
+	// Append stores a record after the last one
+	Append(r AuditRecord) error
+
+	// Last returns the last record stored, nil if there is none
+	Last() (*AuditRecord, error)
+}
+
+// MemoryAuditSink keeps the audit records in memory
+type MemoryAuditSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+// NewMemoryAuditSink returns an empty sink
+func NewMemoryAuditSink() *MemoryAuditSink {
+	return new(MemoryAuditSink)
+}
+
+// Append stores the record
+func (m *MemoryAuditSink) Append(r AuditRecord) error {
+	m.mu.Lock()
+	m.records = append(m.records, r)
+	m.mu.Unlock()
+	return nil
+}
+
+// Last returns the last record
+func (m *MemoryAuditSink) Last() (*AuditRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.records) == 0 {
+		return nil, nil
+	}
+	r := m.records[len(m.records)-1]
+	return &r, nil
+}
+
+// Records returns a copy of the records
+func (m *MemoryAuditSink) Records() []AuditRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]AuditRecord(nil), m.records...)
+}
+
+// FileAuditSink appends the audit records to a file, one JSON object
+// per line. The file is synced after every record. The file must have a
+// single writer: the auditor keeps the last record in memory, so records
+// appended by several processes or auditors fork the chain. Give each
+// process its own file.
+type FileAuditSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileAuditSink returns a sink appending to the file at path
+func NewFileAuditSink(path string) *FileAuditSink {
+	return &FileAuditSink{path: path}
+}
+
+// Append writes the record at the end of the file
+func (f *FileAuditSink) Append(r AuditRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(b, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Last returns the last record of the file
+func (f *FileAuditSink) Last() (*AuditRecord, error) {
+	records, err := f.Records()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[len(records)-1], nil
+}
+
+// Records reads all the records of the file, none if it does not exist
+func (f *FileAuditSink) Records() ([]AuditRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("uphold: invalid audit record %d: %v", len(records)+1, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// Auditor chains the records of the transactions made by a client
+// and appends them to a sink. It must be the only writer of the sink.
+type Auditor struct {
+	Sink AuditSink
+
+	// Actor identifies who makes the transactions, such as a user
+	// or a service name, and is recorded with every record
+	Actor string
+
+	mu     sync.Mutex
+	loaded bool
+	last   *AuditRecord
+}
+
+// NewAuditor returns an auditor appending to sink
+func NewAuditor(sink AuditSink, actor string) *Auditor {
+	return &Auditor{Sink: sink, Actor: actor}
+}
+
+// SetAuditor records the transactions created, committed, cancelled
+// and resent by the client with a, or stops recording if a is nil
+func (c *Client) SetAuditor(a *Auditor) {
+	c.auditor = a
+}
+
+// AuditError is returned along with the result of a transaction
+// call when its audit record could not be stored. The call itself
+// went through as the result tells, it must not be blindly retried.
+type AuditError struct {
+	Err error
+}
+
+// Error returns the string representation of the error
+func (e AuditError) Error() string {
+	return fmt.Sprintf("uphold: audit record not stored: %v", e.Err)
+}
+
+// record chains r after the last record and appends it to the sink
+func (a *Auditor) record(r AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.loaded {
+		last, err := a.Sink.Last()
+		if err != nil {
+			return err
+		}
+		a.last, a.loaded = last, true
+	}
+
+	r.Actor = a.Actor
+	r.Seq = 1
+	if a.last != nil {
+		r.Seq = a.last.Seq + 1
+		r.PrevHash = a.last.Hash
+	}
+
+	var err error
+	if r.Hash, err = r.hash(); err != nil {
+		return err
+	}
+	if err := a.Sink.Append(r); err != nil {
+		return err
+	}
+	a.last = &r
+	return nil
+}
+
+// AuditHead identifies the last record of an audit trail
+type AuditHead struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Head returns the last record appended to the sink, the zero head if
+// there is none. Keeping the head apart from the sink, after each call,
+// lets VerifyAuditHead detect records removed from the end of the trail.
+func (a *Auditor) Head() (AuditHead, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.loaded {
+		last, err := a.Sink.Last()
+		if err != nil {
+			return AuditHead{}, err
+		}
+		a.last, a.loaded = last, true
+	}
+	if a.last == nil {
+		return AuditHead{}, nil
+	}
+	return AuditHead{Seq: a.last.Seq, Hash: a.last.Hash}, nil
+}
+
+// audit records the outcome of a transaction call, if the client has
+// an auditor, and returns it with an AuditError if it failed to
+func (c *Client) audit(r AuditRecord, txn *Txn, resp *Response, err error) (*Txn, *Response, error) {
+	if c.auditor == nil {
+		return txn, resp, err
+	}
+
+	r.Time = time.Now().UTC()
+	if c.ctx != nil {
+		r.RequestID = RequestIDFromContext(c.ctx)
+	}
+	if txn != nil {
+		res := *txn
+		r.Result = &res
+		r.Simulated = txn.Simulated
+		if r.Transaction == "" {
+			r.Transaction = txn.ID
+		}
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if aerr := c.auditor.record(r); aerr != nil && err == nil {
+		err = AuditError{aerr}
+	}
+	return txn, resp, err
+}
+
+// AuditVerifyError tells where the audit trail is broken
+type AuditVerifyError struct {
+	Seq    uint64
+	Reason string
+}
+
+// Error returns the string representation of the error
+func (e AuditVerifyError) Error() string {
+	return fmt.Sprintf("uphold: audit trail broken at record %d: %s", e.Seq, e.Reason)
+}
+
+// VerifyAudit checks that the records form an unbroken chain starting
+// with the first record, and returns an AuditVerifyError telling the
+// first record missing, out of order or modified
+func VerifyAudit(records []AuditRecord) error {
+	prev := ""
+	for i, r := range records {
+		seq := uint64(i + 1)
+		if r.Seq != seq {
+			return AuditVerifyError{seq, fmt.Sprintf("found record %d instead", r.Seq)}
+		}
+		if r.PrevHash != prev {
+			return AuditVerifyError{seq, "previous record hash does not match"}
+		}
+
+		h, err := r.hash()
+		if err != nil {
+			return err
+		}
+		if h != r.Hash {
+			return AuditVerifyError{seq, "record was modified"}
+		}
+		prev = r.Hash
+	}
+	return nil
+}
+
+// VerifyAuditHead checks the chain of the records like VerifyAudit, and
+// that it holds the record of head, a head returned by Auditor.Head
+// earlier and kept apart from the trail. Records removed from the end
+// of the trail leave the chain intact but are detected here. Records
+// appended after head was taken are accepted.
+func VerifyAuditHead(records []AuditRecord, head AuditHead) error {
+	if err := VerifyAudit(records); err != nil {
+		return err
+	}
+	if head.Seq == 0 {
+		return nil
+	}
+	if uint64(len(records)) < head.Seq {
+		return AuditVerifyError{uint64(len(records)) + 1, fmt.Sprintf("trail ends before record %d", head.Seq)}
+	}
+	if records[head.Seq-1].Hash != head.Hash {
+		return AuditVerifyError{head.Seq, "record does not match the head"}
+	}
+	return nil
+}
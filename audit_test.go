@@ -0,0 +1,155 @@
+package uphold
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// auditServer fakes the transaction t1 of card c1
+func auditServer() {
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"t1","status":"pending","denomination":{"amount":"10","currency":"USD"},"destination":{"CardId":"c2"}}`)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/t1/commit", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"t1","status":"completed"}`)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/t1/cancel", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"code":"transaction_not_cancellable"}`)
+	})
+}
+
+func TestAuditTrail(t *testing.T) {
+	setup()
+	defer teardown()
+	auditServer()
+
+	sink := NewMemoryAuditSink()
+	client.SetAuditor(NewAuditor(sink, "payroll"))
+	card := Card{ID: "c1"}
+
+	txn, _, err := client.Transaction.Create(card, Quote{Denomination: &QuoteDenomination{Amount: 10, Currency: CurrencyUSD}, Destination: "c2"})
+	if err != nil {
+		t.Fatalf("Create() returned unexpected error: %v", err)
+	}
+	if _, _, err := client.Transaction.Commit(card, *txn, ""); err != nil {
+		t.Fatalf("Commit() returned unexpected error: %v", err)
+	}
+	if _, _, err := client.Transaction.Cancel(card, *txn); err == nil {
+		t.Fatalf("Cancel() should return an error")
+	}
+
+	records := sink.Records()
+	if len(records) != 3 {
+		t.Fatalf("recorded %d records, want 3", len(records))
+	}
+	if r := records[0]; r.Operation != OperationTransactionCreate || r.Actor != "payroll" || r.Card != "c1" || r.Transaction != "t1" || r.Destination != "c2" || r.Quote == nil || r.PrevHash != "" {
+		t.Errorf("first record is %+v", r)
+	}
+	if r := records[1]; r.Operation != OperationTransactionCommit || r.Result.Status != TxnStatusCompleted || r.Destination != "c2" {
+		t.Errorf("second record is %+v", r)
+	}
+	if r := records[2]; r.Operation != OperationTransactionCancel || r.Error == "" || r.Result != nil {
+		t.Errorf("third record is %+v", r)
+	}
+
+	if err := VerifyAudit(records); err != nil {
+		t.Errorf("VerifyAudit() returned unexpected error: %v", err)
+	}
+}
+
+func TestVerifyAuditTampering(t *testing.T) {
+	sink := NewMemoryAuditSink()
+	a := NewAuditor(sink, "ops")
+	for _, id := range []string{"t1", "t2", "t3"} {
+		a.record(AuditRecord{Operation: OperationTransactionCommit, Card: "c1", Transaction: id})
+	}
+	records := sink.Records()
+
+	modified := append([]AuditRecord(nil), records...)
+	modified[1].Destination = "attacker@example.com"
+
+	tests := []struct {
+		name    string
+		records []AuditRecord
+		seq     uint64
+	}{
+		{"gap", []AuditRecord{records[0], records[2]}, 2},
+		{"missing start", records[1:], 1},
+		{"modified", modified, 2},
+	}
+	for _, tt := range tests {
+		err := VerifyAudit(tt.records)
+		if v, ok := err.(AuditVerifyError); !ok || v.Seq != tt.seq {
+			t.Errorf("VerifyAudit() with %s returned %v, want an error at record %d", tt.name, err, tt.seq)
+		}
+	}
+}
+
+func TestVerifyAuditHead(t *testing.T) {
+	sink := NewMemoryAuditSink()
+	a := NewAuditor(sink, "ops")
+	if head, err := a.Head(); err != nil || head != (AuditHead{}) {
+		t.Errorf("Head() of an empty trail returned %+v, %v", head, err)
+	}
+	for _, id := range []string{"t1", "t2", "t3"} {
+		a.record(AuditRecord{Operation: OperationTransactionCommit, Card: "c1", Transaction: id})
+	}
+	head, err := a.Head()
+	if err != nil {
+		t.Fatalf("Head() returned unexpected error: %v", err)
+	}
+	records := sink.Records()
+
+	if err := VerifyAuditHead(records, head); err != nil {
+		t.Errorf("VerifyAuditHead() returned unexpected error: %v", err)
+	}
+
+	// the truncated trail is a valid chain
+	if err := VerifyAudit(records[:2]); err != nil {
+		t.Errorf("VerifyAudit() of the truncated trail returned unexpected error: %v", err)
+	}
+	if v, ok := VerifyAuditHead(records[:2], head).(AuditVerifyError); !ok || v.Seq != 3 {
+		t.Errorf("VerifyAuditHead() of the truncated trail returned %v, want an error at record 3", v)
+	}
+
+	// a rewritten tail has the right length but not the head
+	forked := NewMemoryAuditSink()
+	b := NewAuditor(forked, "ops")
+	for _, id := range []string{"t1", "t2", "t4"} {
+		b.record(AuditRecord{Operation: OperationTransactionCommit, Card: "c1", Transaction: id})
+	}
+	if v, ok := VerifyAuditHead(forked.Records(), head).(AuditVerifyError); !ok || v.Seq != 3 {
+		t.Errorf("VerifyAuditHead() of a rewritten trail returned %v, want an error at record 3", v)
+	}
+}
+
+func TestFileAuditSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uphold-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	NewAuditor(NewFileAuditSink(path), "ops").record(AuditRecord{Operation: OperationTransactionCreate, Card: "c1"})
+
+	// a new auditor continues the chain of the file
+	sink := NewFileAuditSink(path)
+	NewAuditor(sink, "ops").record(AuditRecord{Operation: OperationTransactionCommit, Card: "c1", Result: &Txn{ID: "t1", Denomination: &Denomination{Amount: 0.1, Currency: "BTC"}}})
+
+	records, err := sink.Records()
+	if err != nil {
+		t.Fatalf("Records() returned unexpected error: %v", err)
+	}
+	if len(records) != 2 || records[1].Seq != 2 {
+		t.Fatalf("file holds %+v, want two chained records", records)
+	}
+	if err := VerifyAudit(records); err != nil {
+		t.Errorf("VerifyAudit() returned unexpected error: %v", err)
+	}
+}
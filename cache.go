@@ -0,0 +1,278 @@
+package uphold
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headerETag and headerIfNoneMatch revalidate cached responses
+const (
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// DefaultCacheTTLs are the cache durations of the read-only endpoints,
+// by template as returned by NormalizeEndpoint
+var DefaultCacheTTLs = map[string]time.Duration{
+	"ticker":            5 * time.Second,
+	"ticker/{currency}": 5 * time.Second,
+	"me/cards":          30 * time.Second,
+	"me/cards/{id}":     30 * time.Second,
+	"me/accounts":       5 * time.Minute,
+	"me/accounts/{id}":  5 * time.Minute,
+}
+
+// CacheEntry is a cached response body
+type CacheEntry struct {
+	Body    []byte
+	Header  http.Header
+	ETag    string
+	Expires time.Time
+}
+
+// CacheBackend stores cached responses. Expired entries are still
+// returned by Get so that they can be revalidated with their ETag.
+type CacheBackend interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, e CacheEntry)
+
+	// DeletePrefix removes all the entries whose key starts with prefix
+	DeletePrefix(prefix string)
+}
+
+// LRUCache is a CacheBackend keeping a limited number of entries in
+// memory, evicting the least recently used ones
+type LRUCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// lruItem is an element of the LRU list
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// NewLRUCache returns an empty LRUCache holding up to size entries
+func NewLRUCache(size int) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// Get returns the entry for key
+func (l *LRUCache) Get(key string) (CacheEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	l.order.MoveToFront(e)
+	return e.Value.(*lruItem).entry, true
+}
+
+// Set stores the entry for key, evicting the least
+// recently used entry if the cache is full
+func (l *LRUCache) Set(key string, entry CacheEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[key]; ok {
+		e.Value.(*lruItem).entry = entry
+		l.order.MoveToFront(e)
+		return
+	}
+
+	l.entries[key] = l.order.PushFront(&lruItem{key: key, entry: entry})
+	for l.size > 0 && l.order.Len() > l.size {
+		last := l.order.Back()
+		l.order.Remove(last)
+		delete(l.entries, last.Value.(*lruItem).key)
+	}
+}
+
+// DeletePrefix removes all the entries whose key starts with prefix
+func (l *LRUCache) DeletePrefix(prefix string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for k, e := range l.entries {
+		if strings.HasPrefix(k, prefix) {
+			l.order.Remove(e)
+			delete(l.entries, k)
+		}
+	}
+}
+
+// Cache caches the responses of GET requests to the endpoints with a
+// TTL. Expired responses carrying an ETag are revalidated with the API.
+// Cards are invalidated once a request modifying them, such as a
+// transaction on the card, succeeds, and the list of cards once any
+// card is added, changed or deleted. Headers are copied in and out
+// of the cache.
+//
+// The cache key does not identify the user, a backend must only be
+// shared by clients of the same user unless each uses its own Namespace.
+type Cache struct {
+	Backend CacheBackend
+
+	// TTLs by endpoint template, endpoints without TTL are not cached
+	TTLs map[string]time.Duration
+
+	// Namespace prefixes the keys of the entries
+	Namespace string
+}
+
+// NewCache returns a cache of up to size entries
+// in memory, using DefaultCacheTTLs
+func NewCache(size int) *Cache {
+	ttls := make(map[string]time.Duration, len(DefaultCacheTTLs))
+	for k, v := range DefaultCacheTTLs {
+		ttls[k] = v
+	}
+	return &Cache{Backend: NewLRUCache(size), TTLs: ttls}
+}
+
+// key returns the cache key of the API path and query
+func (c *Cache) key(path, query string) string {
+	path = strings.TrimPrefix(strings.Trim(path, "/"), "v0/")
+	return c.Namespace + path + "?" + query
+}
+
+// Invalidate removes the entries of the API path and below
+func (c *Cache) Invalidate(path string) {
+	path = strings.TrimPrefix(strings.Trim(path, "/"), "v0/")
+	c.Backend.DeletePrefix(c.Namespace + path)
+}
+
+// InvalidateCard removes the entries of the card, its transactions
+// and the lists of cards and transactions of the user
+func (c *Cache) InvalidateCard(id string) {
+	c.Backend.DeletePrefix(c.key("me/cards", ""))
+	c.Backend.DeletePrefix(c.key("me/transactions", ""))
+	c.Backend.DeletePrefix(c.key("me/cards/"+id, ""))
+	c.Backend.DeletePrefix(c.Namespace + "me/cards/" + id + "/")
+}
+
+// Middleware returns the middleware serving and filling the cache
+func (c *Cache) Middleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			if req.Method != "GET" {
+				resp, err := next.Do(req, v)
+				if err == nil {
+					c.invalidate(req, v)
+				}
+				return resp, err
+			}
+
+			ttl, ok := c.TTLs[NormalizeEndpoint(req.URL.Path)]
+			if !ok {
+				return next.Do(req, v)
+			}
+
+			key := c.key(req.URL.Path, req.URL.RawQuery)
+			entry, cached := c.Backend.Get(key)
+			if cached && time.Now().Before(entry.Expires) {
+				return cachedResponse(req, entry, v)
+			}
+			if cached && entry.ETag != "" {
+				req.Header.Set(headerIfNoneMatch, entry.ETag)
+			}
+
+			buf := new(bytes.Buffer)
+			resp, err := next.Do(req, buf)
+			if e, ok := err.(ErrorResponse); ok && cached && e.Response.StatusCode == http.StatusNotModified {
+				entry.Expires = time.Now().Add(ttl)
+				c.Backend.Set(key, entry)
+
+				r, err := cachedResponse(req, entry, v)
+				r.RequestRate = resp.RequestRate
+				return r, err
+			}
+			if err != nil {
+				return resp, err
+			}
+
+			c.Backend.Set(key, CacheEntry{
+				Body:    buf.Bytes(),
+				Header:  cloneHeader(resp.Header),
+				ETag:    resp.Header.Get(headerETag),
+				Expires: time.Now().Add(ttl),
+			})
+			return resp, decodeBody(buf.Bytes(), v)
+		})
+	}
+}
+
+// invalidate removes the cards modified by a successful request
+func (c *Cache) invalidate(req *http.Request, v interface{}) {
+	if id := pathIDs(req.URL.Path)[AttributeCardID]; id != "" {
+		c.InvalidateCard(id)
+	}
+
+	// adding a card changes the list of cards
+	if strings.HasPrefix(NormalizeEndpoint(req.URL.Path), "me/cards") {
+		c.Backend.DeletePrefix(c.key("me/cards", ""))
+	}
+
+	// transfers also change the balance of the destination card
+	if txn, ok := v.(*Txn); ok && txn.Destination.CardID != "" {
+		c.InvalidateCard(txn.Destination.CardID)
+	}
+}
+
+// cachedResponse serves a request from the cache
+func cachedResponse(req *http.Request, entry CacheEntry, v interface{}) (*Response, error) {
+	resp := &Response{Response: &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     cloneHeader(entry.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(entry.Body)),
+		Request:    req,
+	}}
+	return resp, decodeBody(entry.Body, v)
+}
+
+// cloneHeader returns a copy of h, so that the
+// cache and the callers never share a header
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
+
+// decodeBody stores the body in v as Client.Do does
+func decodeBody(b []byte, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	if w, ok := v.(io.Writer); ok {
+		_, err := w.Write(b)
+		return err
+	}
+
+	err := json.NewDecoder(bytes.NewReader(b)).Decode(v)
+	if err == io.EOF {
+		err = nil // ignore EOF errors caused by empty response body
+	}
+	return err
+}
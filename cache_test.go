@@ -0,0 +1,202 @@
+package uphold
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	l := NewLRUCache(2)
+	l.Set("a", CacheEntry{ETag: "a"})
+	l.Set("b", CacheEntry{ETag: "b"})
+	l.Get("a")
+	l.Set("c", CacheEntry{ETag: "c"})
+
+	if _, ok := l.Get("b"); ok {
+		t.Error("least recently used entry should be evicted")
+	}
+	if e, ok := l.Get("a"); !ok || e.ETag != "a" {
+		t.Errorf("Get(a) returned %v, %v, want entry a", e, ok)
+	}
+
+	l.DeletePrefix("c")
+	if _, ok := l.Get("c"); ok {
+		t.Error("DeletePrefix() should remove the entry")
+	}
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	setup()
+	defer teardown()
+
+	calls := 0
+	mux.HandleFunc("/ticker/USD", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `[{"pair":"BTCUSD"}]`)
+	})
+	mux.HandleFunc("/me/transactions", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `[]`)
+	})
+
+	client.Use(NewCache(10).Middleware())
+
+	for i := 0; i < 2; i++ {
+		pairs, _, err := client.Ticker.List(CurrencyUSD)
+		if err != nil {
+			t.Fatalf("Ticker.List() returned unexpected error: %v", err)
+		}
+		if len(*pairs) != 1 || (*pairs)[0].Pair != "BTCUSD" {
+			t.Errorf("Ticker.List() returned %+v, want BTCUSD", *pairs)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("ticker was requested %d times, want 1", calls)
+	}
+
+	client.Transaction.ListForUser()
+	client.Transaction.ListForUser()
+	if calls != 3 {
+		t.Errorf("uncached endpoint was requested %d times, want 2", calls-1)
+	}
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	setup()
+	defer teardown()
+
+	calls := 0
+	mux.HandleFunc("/me/cards/123", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get(headerIfNoneMatch) == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(headerETag, `"v1"`)
+		fmt.Fprint(w, `{"id":"123","label":"cached"}`)
+	})
+
+	cache := NewCache(10)
+	cache.TTLs["me/cards/{id}"] = time.Nanosecond
+	client.Use(cache.Middleware())
+
+	for i := 0; i < 2; i++ {
+		card, resp, err := client.Card.List("123")
+		if err != nil {
+			t.Fatalf("Card.List() returned unexpected error: %v", err)
+		}
+		if card.Label != "cached" || resp.StatusCode != http.StatusOK {
+			t.Errorf("Card.List() returned %+v with status %d, want cached card", card, resp.StatusCode)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if calls != 2 {
+		t.Errorf("card was requested %d times, want 2", calls)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	setup()
+	defer teardown()
+
+	calls := map[string]int{}
+	mux.HandleFunc("/me/cards/", func(w http.ResponseWriter, r *http.Request) {
+		calls[r.Method+" "+r.URL.Path]++
+		switch r.URL.Path {
+		case "/me/cards/123/transactions":
+			fmt.Fprint(w, `{"id":"1","destination":{"CardId":"456"}}`)
+		default:
+			fmt.Fprint(w, `{"id":"card"}`)
+		}
+	})
+
+	client.Use(NewCache(10).Middleware())
+
+	get := func() {
+		client.Card.List("123")
+		client.Card.List("456")
+		client.Card.List("789")
+	}
+	get()
+	if _, _, err := client.Transaction.Create(Card{ID: "123"}, Quote{Destination: "456"}); err != nil {
+		t.Fatalf("Transaction.Create() returned unexpected error: %v", err)
+	}
+	get()
+
+	want := map[string]int{
+		"GET /me/cards/123":               2,
+		"GET /me/cards/456":               2,
+		"GET /me/cards/789":               1,
+		"POST /me/cards/123/transactions": 1,
+	}
+	for k, n := range want {
+		if calls[k] != n {
+			t.Errorf("%s was requested %d times, want %d", k, calls[k], n)
+		}
+	}
+}
+
+func TestCacheInvalidateCardList(t *testing.T) {
+	setup()
+	defer teardown()
+
+	lists := 0
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			fmt.Fprint(w, `{"id":"new"}`)
+			return
+		}
+		lists++
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/me/cards/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"old","balance":"0"}`)
+	})
+
+	client.Use(NewCache(10).Middleware())
+
+	client.Card.ListAll()
+	if _, _, err := client.Card.Add(Card{Label: "new", Currency: "USD"}); err != nil {
+		t.Fatalf("Card.Add() returned unexpected error: %v", err)
+	}
+	client.Card.ListAll()
+	if _, err := client.Card.Delete(Card{ID: "old"}); err != nil {
+		t.Fatalf("Card.Delete() returned unexpected error: %v", err)
+	}
+	client.Card.ListAll()
+
+	if lists != 3 {
+		t.Errorf("cards were listed %d times, want 3", lists)
+	}
+}
+
+func TestCacheHeaderCopy(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/ticker/USD", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "api")
+		fmt.Fprint(w, `[]`)
+	})
+
+	client.Use(NewCache(10).Middleware())
+
+	_, resp, err := client.Ticker.List(CurrencyUSD)
+	if err != nil {
+		t.Fatalf("Ticker.List() returned unexpected error: %v", err)
+	}
+	resp.Header.Set("X-Test", "caller")
+
+	for i := 0; i < 2; i++ {
+		_, resp, err = client.Ticker.List(CurrencyUSD)
+		if err != nil {
+			t.Fatalf("Ticker.List() returned unexpected error: %v", err)
+		}
+		if got := resp.Header.Get("X-Test"); got != "api" {
+			t.Errorf("cached response header is %q, want api", got)
+		}
+		resp.Header.Set("X-Test", "caller")
+	}
+}
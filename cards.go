@@ -1,6 +1,11 @@
 package uphold
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // CardService works with card API endpoints
 type CardService struct {
@@ -9,7 +14,7 @@ type CardService struct {
 
 // ListAll lists all available cards
 func (c *CardService) ListAll() (*[]Card, *Response, error) {
-	req, err := c.client.NewRequest("GET", "me/cards", nil)
+	req, err := c.client.newRequest(OperationCardListAll, "", "GET", "me/cards", nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -27,7 +32,7 @@ func (c *CardService) ListAll() (*[]Card, *Response, error) {
 func (c *CardService) List(ID string) (*Card, *Response, error) {
 	rel := fmt.Sprintf("me/cards/%s", ID)
 
-	req, err := c.client.NewRequest("GET", rel, nil)
+	req, err := c.client.newRequest(OperationCardList, "", "GET", rel, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -47,7 +52,7 @@ func (c *CardService) Add(n Card) (*Card, *Response, error) {
 	payload.Label = n.Label
 	payload.Currency = n.Currency
 
-	req, err := c.client.NewRequest("POST", "me/cards", payload)
+	req, err := c.client.newRequest(OperationCardAdd, "", "POST", "me/cards", payload)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -57,6 +62,7 @@ func (c *CardService) Add(n Card) (*Card, *Response, error) {
 	if err != nil {
 		return nil, resp, err
 	}
+	card.Simulated = RequestSimulated(req)
 
 	return card, resp, nil
 }
@@ -71,7 +77,7 @@ func (c *CardService) Update(o Card) (*Card, *Response, error) {
 
 	rel := fmt.Sprintf("me/cards/%s", o.ID)
 
-	req, err := c.client.NewRequest("PATCH", rel, payload)
+	req, err := c.client.newRequest(OperationCardUpdate, "", "PATCH", rel, payload)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -81,6 +87,277 @@ func (c *CardService) Update(o Card) (*Card, *Response, error) {
 	if err != nil {
 		return nil, resp, err
 	}
+	if RequestSimulated(req) {
+		*card = o
+		card.Simulated = true
+	}
+
+	return card, resp, nil
+}
+
+// CardNotEmptyError is returned when deleting a card holding funds
+type CardNotEmptyError struct {
+	Card Card
+}
+
+// Error returns the string representation of the error
+func (e CardNotEmptyError) Error() string {
+	return fmt.Sprintf("uphold: card %s still holds %g %s", e.Card.ID, e.Card.Balance, e.Card.Currency)
+}
+
+// Delete a card from user account. The card is fetched first and a
+// CardNotEmptyError is returned, without deleting it, if its balance
+// is not zero. See SweepAndDelete to move the funds out first.
+func (c *CardService) Delete(card Card) (*Response, error) {
+	current, resp, err := c.List(card.ID)
+	if err != nil {
+		return resp, err
+	}
+	if current.Balance != 0 {
+		return resp, CardNotEmptyError{*current}
+	}
+
+	return c.delete(card)
+}
+
+// delete sends the deletion of the card
+func (c *CardService) delete(card Card) (*Response, error) {
+	rel := fmt.Sprintf("me/cards/%s", card.ID)
+
+	req, err := c.client.newRequest(OperationCardDelete, "", "DELETE", rel, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.client.Do(req, nil)
+}
+
+// cardAmounts is a card with its amounts exactly as sent by the API
+type cardAmounts struct {
+	Card
+	Available string `json:"available"`
+	Balance   string `json:"balance"`
+}
+
+// listAmounts fetches the card with its exact amounts. The amounts of
+// the card are set too, an error is returned if they are not numbers.
+func (c *CardService) listAmounts(ID string) (*cardAmounts, *Response, error) {
+	rel := fmt.Sprintf("me/cards/%s", ID)
+
+	req, err := c.client.newRequest(OperationCardList, "", "GET", rel, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	card := new(cardAmounts)
+	resp, err := c.client.Do(req, card)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	for _, a := range []struct {
+		raw string
+		f   *float32
+	}{{card.Available, &card.Card.Available}, {card.Balance, &card.Card.Balance}} {
+		if a.raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(a.raw, 32)
+		if err != nil {
+			return nil, resp, fmt.Errorf("uphold: invalid amount %q on card %s", a.raw, ID)
+		}
+		*a.f = float32(v)
+	}
+	return card, resp, nil
+}
+
+// SweepAndDelete transfers the balance of card to another card of the
+// user with a realtime transaction, then deletes card. The balance is
+// sent exactly as the API gives it. Funds which are not available yet
+// cannot be moved, a CardNotEmptyError is returned without transferring
+// anything if the card holds any. The transaction is returned if it was
+// made, even when the deletion failed.
+func (c *CardService) SweepAndDelete(card Card, to Card) (*Txn, *Response, error) {
+	if to.ID == "" || strings.EqualFold(to.ID, card.ID) {
+		return nil, nil, fmt.Errorf("uphold: cannot sweep card %s into %q", card.ID, to.ID)
+	}
+
+	current, resp, err := c.listAmounts(card.ID)
+	if err != nil {
+		return nil, resp, err
+	}
+	if current.Card.Balance == 0 {
+		resp, err := c.delete(current.Card)
+		return nil, resp, err
+	}
+	if current.Available != current.Balance {
+		return nil, resp, CardNotEmptyError{current.Card}
+	}
+
+	q := Quote{
+		Denomination: &QuoteDenomination{Amount: current.Card.Available, Currency: CurrencyCode(current.Currency), exact: current.Available},
+		Destination:  to.ID,
+		Realtime:     true,
+	}
+	txn, resp, err := c.client.Transaction.Create(current.Card, q)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	// the balance is not moved in dry-run mode
+	if txn.Simulated {
+		resp, err = c.delete(current.Card)
+	} else {
+		resp, err = c.Delete(current.Card)
+	}
+	return txn, resp, err
+}
+
+// CardFilter selects cards by currency, star and label
+type CardFilter struct {
+	// Currency of the cards, any if empty
+	Currency CurrencyCode
+
+	// Starred selects only the starred cards if set
+	Starred bool
+
+	// Label the cards contain, ignoring case, any if empty
+	Label string
+}
+
+// Match reports whether the card is selected by the filter
+func (f CardFilter) Match(card Card) bool {
+	if f.Currency != "" && CurrencyCode(card.Currency) != f.Currency {
+		return false
+	}
+	if f.Starred && (card.Settings == nil || !card.Settings.Starred) {
+		return false
+	}
+	if f.Label != "" && !strings.Contains(strings.ToLower(card.Label), strings.ToLower(f.Label)) {
+		return false
+	}
+	return true
+}
+
+// Filter returns the cards selected by the filter, in order
+func (f CardFilter) Filter(cards []Card) []Card {
+	selected := []Card{}
+	for _, card := range cards {
+		if f.Match(card) {
+			selected = append(selected, card)
+		}
+	}
+	return selected
+}
+
+// ListMatching lists the cards selected by the filter
+func (c *CardService) ListMatching(f CardFilter) (*[]Card, *Response, error) {
+	cards, resp, err := c.ListAll()
+	if err != nil {
+		return nil, resp, err
+	}
+
+	selected := f.Filter(*cards)
+	return &selected, resp, nil
+}
+
+// CardPatch is a partial update of a card. Only the fields which are
+// set are sent, so that a card can be unstarred, which Update cannot
+// do. Positions start at 1. Use Bool, Int and String to set the fields.
+type CardPatch struct {
+	Label    *string
+	Position *int
+	Starred  *bool
+}
+
+// MarshalJSON encodes the fields which are set, zero values included
+func (p CardPatch) MarshalJSON() ([]byte, error) {
+	type settings struct {
+		Position *int  `json:"position,omitempty"`
+		Starred  *bool `json:"starred,omitempty"`
+	}
+	payload := struct {
+		Label    *string   `json:"label,omitempty"`
+		Settings *settings `json:"settings,omitempty"`
+	}{Label: p.Label}
+
+	if p.Position != nil || p.Starred != nil {
+		payload.Settings = &settings{p.Position, p.Starred}
+	}
+	return json.Marshal(payload)
+}
+
+// Bool returns a pointer to v, to set the fields of a CardPatch
+func Bool(v bool) *bool { return &v }
+
+// Int returns a pointer to v, to set the fields of a CardPatch
+func Int(v int) *int { return &v }
+
+// String returns a pointer to v, to set the fields of a CardPatch
+func String(v string) *string { return &v }
+
+// Patch updates the fields of the card with given ID which are set in p
+func (c *CardService) Patch(ID string, p CardPatch) (*Card, *Response, error) {
+	rel := fmt.Sprintf("me/cards/%s", ID)
+
+	req, err := c.client.newRequest(OperationCardUpdate, "", "PATCH", rel, p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	card := new(Card)
+	resp, err := c.client.Do(req, card)
+	if err != nil {
+		return nil, resp, err
+	}
+	if RequestSimulated(req) {
+		card.ID = ID
+		card.Simulated = true
+	}
 
 	return card, resp, nil
 }
+
+// Star stars or unstars the card with given ID
+func (c *CardService) Star(ID string, starred bool) (*Card, *Response, error) {
+	return c.Patch(ID, CardPatch{Starred: &starred})
+}
+
+// Relabel changes the label of the card with given ID
+func (c *CardService) Relabel(ID string, label string) (*Card, *Response, error) {
+	return c.Patch(ID, CardPatch{Label: &label})
+}
+
+// ReorderError is returned when a card could not be moved by Reorder.
+// The cards before it in the list were moved, the ones after were not.
+type ReorderError struct {
+	Card    Card
+	Updated []Card
+	Err     error
+}
+
+// Error returns the string representation of the error
+func (e ReorderError) Error() string {
+	return fmt.Sprintf("uphold: card %s not moved, %d cards moved before: %v", e.Card.ID, len(e.Updated), e.Err)
+}
+
+// Reorder sets the positions of the cards to their order in the list,
+// starting at 1. Every card is updated, their settings may be stale.
+// The cards updated are returned. The first failure stops the reordering
+// and is returned as a ReorderError telling which cards were moved.
+func (c *CardService) Reorder(cards []Card) (*[]Card, *Response, error) {
+	updated := []Card{}
+	var resp *Response
+
+	for i, card := range cards {
+		position := i + 1
+
+		u, r, err := c.Patch(card.ID, CardPatch{Position: &position})
+		if err != nil {
+			return &updated, r, ReorderError{Card: card, Updated: updated, Err: err}
+		}
+		updated = append(updated, *u)
+		resp = r
+	}
+	return &updated, resp, nil
+}
@@ -2,8 +2,10 @@ package uphold
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -319,3 +321,198 @@ func TestCardUpdate(t *testing.T) {
 		t.Errorf("Card.ListAll() returned %+v, want %+v", cards, want)
 	}
 }
+
+func TestCardDelete(t *testing.T) {
+	setup()
+	defer teardown()
+
+	deleted := false
+	mux.HandleFunc("/me/cards/c1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"id":"c1","currency":"USD","balance":"0.00","available":"0.00"}`)
+	})
+
+	if _, err := client.Card.Delete(Card{ID: "c1"}); err != nil {
+		t.Fatalf("Card.Delete() returned unexpected error: %v", err)
+	}
+	if !deleted {
+		t.Errorf("Card.Delete() did not delete the card")
+	}
+}
+
+func TestCardDeleteNotEmpty(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"id":"c1","currency":"USD","balance":"12.50","available":"12.50"}`)
+	})
+
+	_, err := client.Card.Delete(Card{ID: "c1"})
+	if e, ok := err.(CardNotEmptyError); !ok || e.Card.Balance != 12.5 {
+		t.Errorf("Card.Delete() returned %v, want a CardNotEmptyError", err)
+	}
+}
+
+func TestCardSweepAndDelete(t *testing.T) {
+	setup()
+	defer teardown()
+
+	balance := "12.345678901234"
+	deleted := false
+	mux.HandleFunc("/me/cards/c1", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "DELETE" {
+			deleted = true
+			return
+		}
+		fmt.Fprintf(w, `{"id":"c1","currency":"USD","balance":"%s","available":"%s"}`, balance, balance)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testFormValues(t, r, values{"commit": "true"})
+		testBody(t, r, `{"denomination":{"amount":"12.345678901234","currency":"USD"},"destination":"c2"}`)
+		balance = "0.00"
+		fmt.Fprint(w, `{"id":"t1","status":"completed"}`)
+	})
+
+	txn, _, err := client.Card.SweepAndDelete(Card{ID: "c1"}, Card{ID: "c2"})
+	if err != nil {
+		t.Fatalf("Card.SweepAndDelete() returned unexpected error: %v", err)
+	}
+	if txn == nil || txn.ID != "t1" || !deleted {
+		t.Errorf("Card.SweepAndDelete() returned %+v, deleted %v", txn, deleted)
+	}
+}
+
+func TestCardSweepAndDeleteSameCard(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected %s request", r.Method)
+	})
+
+	for _, to := range []Card{{ID: "c1"}, {}} {
+		if _, _, err := client.Card.SweepAndDelete(Card{ID: "c1"}, to); err == nil {
+			t.Errorf("Card.SweepAndDelete() into %q returned no error", to.ID)
+		}
+	}
+}
+
+func TestCardFilter(t *testing.T) {
+	cards := []Card{
+		{ID: "1", Currency: "USD", Label: "Savings", Settings: &CardSettings{Starred: true}},
+		{ID: "2", Currency: "USD", Label: "Spending"},
+		{ID: "3", Currency: "BTC", Label: "Cold savings", Settings: &CardSettings{Starred: true}},
+	}
+
+	tests := []struct {
+		filter CardFilter
+		want   []string
+	}{
+		{CardFilter{}, []string{"1", "2", "3"}},
+		{CardFilter{Currency: CurrencyUSD}, []string{"1", "2"}},
+		{CardFilter{Starred: true}, []string{"1", "3"}},
+		{CardFilter{Label: "SAVINGS"}, []string{"1", "3"}},
+		{CardFilter{Currency: CurrencyBTC, Label: "spend"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		got := []string{}
+		for _, c := range tt.filter.Filter(cards) {
+			got = append(got, c.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v selected %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestCardPatch(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PATCH")
+		testBody(t, r, `{"settings":{"position":1,"starred":false}}`)
+		fmt.Fprint(w, `{"id":"c1","settings":{"position":1,"starred":false}}`)
+	})
+
+	card, _, err := client.Card.Patch("c1", CardPatch{Position: Int(1), Starred: Bool(false)})
+	if err != nil {
+		t.Fatalf("Card.Patch() returned unexpected error: %v", err)
+	}
+	if card.ID != "c1" || card.Settings == nil || card.Settings.Starred {
+		t.Errorf("Card.Patch() returned %+v", card)
+	}
+}
+
+func TestCardPatchMarshal(t *testing.T) {
+	tests := []struct {
+		patch CardPatch
+		want  string
+	}{
+		{CardPatch{}, `{}`},
+		{CardPatch{Label: String("")}, `{"label":""}`},
+		{CardPatch{Label: String("l"), Starred: Bool(true)}, `{"label":"l","settings":{"starred":true}}`},
+	}
+
+	for _, tt := range tests {
+		b, err := tt.patch.MarshalJSON()
+		if err != nil || string(b) != tt.want {
+			t.Errorf("MarshalJSON() returned %s, %v, want %s", b, err, tt.want)
+		}
+	}
+}
+
+func TestCardReorder(t *testing.T) {
+	setup()
+	defer teardown()
+
+	positions := map[string]string{}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		id := id
+		mux.HandleFunc("/me/cards/"+id, func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "PATCH")
+			if id == "d" {
+				http.Error(w, `{"code":"not_found"}`, http.StatusNotFound)
+				return
+			}
+			b, _ := ioutil.ReadAll(r.Body)
+			positions[id] = strings.TrimSpace(string(b))
+			fmt.Fprintf(w, `{"id":"%s"}`, id)
+		})
+	}
+
+	// the settings may be stale, every card is moved
+	cards := []Card{
+		{ID: "c", Settings: &CardSettings{Position: 3}},
+		{ID: "a", Settings: &CardSettings{Position: 2}},
+		{ID: "b"},
+	}
+	updated, _, err := client.Card.Reorder(cards)
+	if err != nil {
+		t.Fatalf("Card.Reorder() returned unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		"c": `{"settings":{"position":1}}`,
+		"a": `{"settings":{"position":2}}`,
+		"b": `{"settings":{"position":3}}`,
+	}
+	if len(*updated) != 3 || !reflect.DeepEqual(positions, want) {
+		t.Errorf("Card.Reorder() sent %v and returned %+v, want %v", positions, updated, want)
+	}
+
+	_, _, err = client.Card.Reorder([]Card{{ID: "a"}, {ID: "d"}, {ID: "b"}})
+	e, ok := err.(ReorderError)
+	if !ok || e.Card.ID != "d" || len(e.Updated) != 1 || e.Updated[0].ID != "a" {
+		t.Errorf("Card.Reorder() returned %v, want a ReorderError on d after moving a", err)
+	}
+}
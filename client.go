@@ -2,12 +2,14 @@ package uphold
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,12 +18,17 @@ import (
 
 // Various URL endpoints defined by Uphold
 const (
-	LiveAuthURL    = "https://uphold.com/authorize/"
-	SandBoxAuthURL = "https://sandbox.uphold.com/authorize/"
-	TokenAccessURL = "https://api.uphold.com/oauth2/token"
-	APIURL         = "https://api.uphold.com/v0/"
+	LiveAuthURL           = "https://uphold.com/authorize/"
+	SandBoxAuthURL        = "https://sandbox.uphold.com/authorize/"
+	TokenAccessURL        = "https://api.uphold.com/oauth2/token"
+	SandboxTokenAccessURL = "https://api-sandbox.uphold.com/oauth2/token"
+	APIURL                = "https://api.uphold.com/v0/"
+	SandboxAPIURL         = "https://api-sandbox.uphold.com/v0/"
 )
 
+// defaultHTTPClient is used when NewClient is given a nil client
+var defaultHTTPClient = http.DefaultClient
+
 const (
 	libVersion         = "0.1"
 	userAgent          = "gufran-uphold/" + libVersion
@@ -40,6 +47,9 @@ const (
 
 	// The time, in seconds, until the end of the current window duration
 	headerRetryAfter = "Retry-After"
+
+	// The one time password required by some operations
+	headerOTP = "OTP-Token"
 )
 
 // Credential pair to use for oAuth request
@@ -69,18 +79,50 @@ type Client struct {
 	tokenAccessURL *url.URL
 	apiURL         *url.URL
 
-	rateMu sync.Mutex
-	rate   RequestRate
+	// rate is shared with the copies made by WithContext
+	rate *rateState
+
+	// ctx, if set, is attached to every request
+	ctx context.Context
+
+	// middleware wraps Do, outermost first
+	middleware []Middleware
+
+	// dryRun simulates the calls changing anything
+	dryRun bool
+
+	// auditor, if set, records the transactions
+	auditor *Auditor
+
+	// policy, if set, guards the transactions
+	policy *Policy
+
+	// authorize, if set, authenticates every request
+	authorize func(req *http.Request) error
+
+	// application, if set, authenticates the application operations
+	application *applicationAuth
+
+	// scopes granted to the token, nil if unknown
+	scopes []Permission
 
-	Ticker      *TickerService
-	Account     *AccountService
-	Card        *CardService
-	Contact     *ContactService
-	Transaction *TransactionService
+	Ticker        *TickerService
+	Account       *AccountService
+	Card          *CardService
+	Contact       *ContactService
+	Transaction   *TransactionService
+	PersonalToken *PersonalTokenService
 }
 
-// NewClient returns an Uphold API client
+// NewClient returns an Uphold API client. The http client is expected to
+// handle the authentication, typically an OAuth client. Alternatively use
+// http.DefaultClient, or nil, along with SetBasicAuth or
+// SetPersonalAccessToken.
 func NewClient(http *http.Client) *Client {
+	if http == nil {
+		http = defaultHTTPClient
+	}
+
 	authURL, _ := url.Parse(LiveAuthURL)
 	tokenURL, _ := url.Parse(TokenAccessURL)
 	apiURL, _ := url.Parse(APIURL)
@@ -91,22 +133,95 @@ func NewClient(http *http.Client) *Client {
 		authURL:        authURL,
 		tokenAccessURL: tokenURL,
 		apiURL:         apiURL,
-		rate:           RequestRate{},
+		rate:           new(rateState),
 	}
+	c.setServices()
+
+	return c
+}
 
+// setServices points the services at c
+func (c *Client) setServices() {
 	c.Ticker = &TickerService{client: c}
 	c.Account = &AccountService{client: c}
 	c.Card = &CardService{client: c}
 	c.Contact = &ContactService{client: c}
 	c.Transaction = &TransactionService{client: c}
+	c.PersonalToken = &PersonalTokenService{client: c}
+}
 
-	return c
+// WithContext returns a copy of the client which attaches ctx to every
+// request, to cancel them or to pass values such as a request ID to the
+// middleware. The copy shares the configuration and rate of c.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c2 := new(Client)
+	*c2 = *c
+	c2.ctx = ctx
+	c2.setServices()
+	return c2
 }
 
-// UseSandbox uses the sandbox URL for authentication
+// UseSandbox uses the sandbox environment for
+// authentication, token requests and API requests
 func (c *Client) UseSandbox() {
-	u, _ := url.ParseRequestURI(SandBoxAuthURL)
-	c.authURL = u
+	c.authURL, _ = url.Parse(SandBoxAuthURL)
+	c.tokenAccessURL, _ = url.Parse(SandboxTokenAccessURL)
+	c.apiURL, _ = url.Parse(SandboxAPIURL)
+}
+
+// UseLive uses the live environment for authentication,
+// token requests and API requests. This is the default.
+func (c *Client) UseLive() {
+	c.authURL, _ = url.Parse(LiveAuthURL)
+	c.tokenAccessURL, _ = url.Parse(TokenAccessURL)
+	c.apiURL, _ = url.Parse(APIURL)
+}
+
+// Terminals returns the OAuth endpoints of the environment
+// used by the client along with given redirect URL
+func (c *Client) Terminals(redirectURL string) Terminals {
+	return Terminals{
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  c.authURL.String(),
+			TokenURL: c.tokenAccessURL.String(),
+		},
+		RedirectURL: redirectURL,
+	}
+}
+
+// SetBasicAuth authenticates every request with the username and
+// password of the user, as required to create personal access tokens
+func (c *Client) SetBasicAuth(username, password string) {
+	c.authorize = func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	}
+}
+
+// SetPersonalAccessToken authenticates every request
+// with a personal access token of the user
+func (c *Client) SetPersonalAccessToken(token string) {
+	c.authorize = func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// SetAPIURL changes the base URL used for API requests, for instance
+// to point the client at a proxy or a fake server. A trailing slash is
+// appended if missing so relative URLs resolve beneath it.
+func (c *Client) SetAPIURL(urlStr string) error {
+	if !strings.HasSuffix(urlStr, "/") {
+		urlStr += "/"
+	}
+
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return err
+	}
+
+	c.apiURL = u
+	return nil
 }
 
 // NewRequest creates an API request. A relative URL can be provided in url,
@@ -136,10 +251,20 @@ func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Requ
 		return nil, err
 	}
 
+	if c.ctx != nil {
+		req = req.WithContext(c.ctx)
+	}
+
 	req.Header.Add("Accept", defaultContentType)
 	if c.UserAgent != "" {
 		req.Header.Add("User-Agent", c.UserAgent)
 	}
+
+	if c.authorize != nil {
+		if err := c.authorize(req); err != nil {
+			return nil, err
+		}
+	}
 	return req, nil
 }
 
@@ -148,8 +273,48 @@ func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Requ
 // error if an API error has occurred.  If v implements the io.Writer
 // interface, the raw response body will be written to v, without attempting to
 // first decode it.
+//
+// The request passes through the middleware added with Use before it is sent.
 func (c *Client) Do(req *http.Request, v interface{}) (*Response, error) {
-	resp, err := c.http.Do(req)
+	var d Doer = DoerFunc(c.do)
+	if RequestSimulated(req) {
+		d = DoerFunc(c.simulate)
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		d = c.middleware[i](d)
+	}
+	return d.Do(req, v)
+}
+
+// withoutOAuth returns hc without the OAuth transport which would
+// replace the authorization of a request, keeping the transport it
+// wraps so that proxies, TLS settings and recorders still apply
+func withoutOAuth(hc *http.Client) *http.Client {
+	rt := hc.Transport
+	for {
+		t, ok := rt.(*oauth2.Transport)
+		if !ok {
+			break
+		}
+		rt = t.Base
+	}
+	if rt == hc.Transport {
+		return hc
+	}
+
+	c := *hc
+	c.Transport = rt
+	return &c
+}
+
+// do sends the request and decodes the response
+func (c *Client) do(req *http.Request, v interface{}) (*Response, error) {
+	hc := c.http
+	if hasOwnAuth(req) {
+		hc = withoutOAuth(hc)
+	}
+
+	resp, err := hc.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -162,9 +327,9 @@ func (c *Client) Do(req *http.Request, v interface{}) (*Response, error) {
 
 	response := newResponse(resp)
 
-	c.rateMu.Lock()
-	c.rate = response.RequestRate
-	c.rateMu.Unlock()
+	c.rate.Lock()
+	c.rate.rate = response.RequestRate
+	c.rate.Unlock()
 
 	err = CheckResponse(resp)
 	if err != nil {
@@ -227,12 +392,18 @@ type RequestRate struct {
 // most recent API call.  If the client is used in a multi-user application,
 // this rate may not always be up-to-date.
 func (c *Client) Rate() RequestRate {
-	c.rateMu.Lock()
-	rate := c.rate
-	c.rateMu.Unlock()
+	c.rate.Lock()
+	rate := c.rate.rate
+	c.rate.Unlock()
 	return rate
 }
 
+// rateState holds the rate limit shared by a client and its copies
+type rateState struct {
+	sync.Mutex
+	rate RequestRate
+}
+
 // Response contains the API response and request rate information
 type Response struct {
 	*http.Response
@@ -267,6 +438,46 @@ func parseRate(r *http.Response) RequestRate {
 	return rate
 }
 
+// RevokeToken revokes an OAuth access token, for instance when the
+// user logs out. As required by RFC 7009 the request is authenticated
+// with the credentials of the application the token was issued to.
+func (c *Client) RevokeToken(cred Credential, token string) (*Response, error) {
+	rel, err := url.Parse("revoke")
+	if err != nil {
+		return nil, err
+	}
+	u := c.tokenAccessURL.ResolveReference(rel)
+
+	req, err := c.NewRequest("POST", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body := url.Values{"token": {token}}.Encode()
+	req.Body = ioutil.NopCloser(strings.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(cred.ClientID, cred.ClientSecret)
+
+	return c.Do(withOwnAuth(req), nil)
+}
+
+// ownAuthKey is the context key marking requests
+// which carry their own authentication
+type ownAuthKey struct{}
+
+// withOwnAuth marks req as carrying its own authentication, so it is
+// not sent through an OAuth transport of the user which would replace it
+func withOwnAuth(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), ownAuthKey{}, true))
+}
+
+// hasOwnAuth reports whether req carries its own authentication
+func hasOwnAuth(req *http.Request) bool {
+	a, _ := req.Context().Value(ownAuthKey{}).(bool)
+	return a
+}
+
 // ConfigureOAuth returns an OAuth configuration
 func ConfigureOAuth(c Credential, t Terminals, s []Permission) *oauth2.Config {
 	return &oauth2.Config{
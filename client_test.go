@@ -366,3 +366,22 @@ func TestCheckResponse(t *testing.T) {
 		t.Errorf("Error = %#v, want %#v", err, want)
 	}
 }
+
+func TestSetAPIURL(t *testing.T) {
+	c := NewClient(http.DefaultClient)
+	if err := c.SetAPIURL("http://localhost:8080/v0"); err != nil {
+		t.Fatalf("SetAPIURL returned unexpected error: %v", err)
+	}
+
+	req, _ := c.NewRequest("GET", "me/cards", nil)
+	if got, want := req.URL.String(), "http://localhost:8080/v0/me/cards"; got != want {
+		t.Errorf("NewRequest URL is %v, want %v", got, want)
+	}
+}
+
+func TestNewClientNilHTTPClient(t *testing.T) {
+	c := NewClient(nil)
+	if c.http != http.DefaultClient {
+		t.Errorf("NewClient(nil) http client is %v, want http.DefaultClient", c.http)
+	}
+}
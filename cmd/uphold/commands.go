@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gufran/uphold"
+	"golang.org/x/oauth2"
+)
+
+// loginTimeout is how long login waits for the authorization callback
+const loginTimeout = 5 * time.Minute
+
+// newFlagSet returns a flag set for a subcommand
+func newFlagSet(e *env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("uphold "+name, flag.ContinueOnError)
+	fs.SetOutput(e.err)
+	return fs
+}
+
+func runLogin(e *env, args []string) error {
+	if err := newFlagSet(e, "login").Parse(args); err != nil {
+		return err
+	}
+	if e.profile.ClientID == "" || e.profile.ClientSecret == "" {
+		return fmt.Errorf("profile %q has no client credentials", e.profileName)
+	}
+
+	conf := e.profile.oauthConfig()
+	redirect, err := url.Parse(conf.RedirectURL)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	state := hex.EncodeToString(b)
+
+	ln, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	codes := make(chan string, 1)
+	failures := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(redirect.Path, func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("state") != state {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		if msg := r.FormValue("error"); msg != "" {
+			http.Error(w, "authorization failed", http.StatusForbidden)
+			select {
+			case failures <- fmt.Errorf("authorization failed: %s", msg):
+			default:
+			}
+			return
+		}
+
+		fmt.Fprintln(w, "Login complete, you can close this window.")
+		// a repeated callback must not block the handler
+		select {
+		case codes <- r.FormValue("code"):
+		default:
+		}
+	})
+	go http.Serve(ln, mux)
+
+	fmt.Fprintf(e.err, "Open the following URL in your browser to authorize the application:\n\n  %s\n\n",
+		conf.AuthCodeURL(state, oauth2.AccessTypeOffline))
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-failures:
+		return err
+	case <-time.After(loginTimeout):
+		return errors.New("timed out waiting for authorization")
+	}
+	// stops serving the callback
+	ln.Close()
+
+	tok, err := conf.Exchange(oauth2.NoContext, code)
+	if err != nil {
+		return err
+	}
+	store, err := e.tokenStore(true)
+	if err != nil {
+		return err
+	}
+	if err := store.Save(tok); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.err, "Token saved to %s\n", e.tokenPath())
+	return nil
+}
+
+func runCards(e *env, args []string) error {
+	if err := newFlagSet(e, "cards").Parse(args); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	cards, _, err := c.Card.ListAll()
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"ID", "LABEL", "CURRENCY", "ADDRESSES"}}
+	for _, card := range *cards {
+		var addrs []string
+		for _, network := range sortedKeys(card.Address) {
+			addrs = append(addrs, network+":"+card.Address[network])
+		}
+		t.rows = append(t.rows, []string{card.ID, card.Label, card.Currency, strings.Join(addrs, " ")})
+	}
+	return render(e.out, e.format, t, cards)
+}
+
+func runBalances(e *env, args []string) error {
+	fs := newFlagSet(e, "balances")
+	all := fs.Bool("all", false, "include cards with zero balance")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	cards, _, err := c.Card.ListAll()
+	if err != nil {
+		return err
+	}
+
+	var shown []uphold.Card
+	t := table{header: []string{"ID", "LABEL", "CURRENCY", "BALANCE", "AVAILABLE", "NORMALIZED"}}
+	for _, card := range *cards {
+		if card.Balance == 0 && !*all {
+			continue
+		}
+
+		var normalized []string
+		for _, n := range card.Normalized {
+			normalized = append(normalized, amount(n.Balance)+" "+n.Currency)
+		}
+
+		shown = append(shown, card)
+		t.rows = append(t.rows, []string{
+			card.ID, card.Label, card.Currency,
+			amount(card.Balance), amount(card.Available),
+			strings.Join(normalized, " "),
+		})
+	}
+	return render(e.out, e.format, t, shown)
+}
+
+func runTransactions(e *env, args []string) error {
+	fs := newFlagSet(e, "transactions")
+	cardID := fs.String("card", "", "only list transactions of the card with this `id`")
+	output := fs.String("o", "", "export to `file` instead of standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	var txns *[]uphold.Txn
+	if *cardID != "" {
+		txns, _, err = c.Transaction.ListForCard(uphold.Card{ID: *cardID})
+	} else {
+		txns, _, err = c.Transaction.ListForUser()
+	}
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"ID", "CREATED", "TYPE", "STATUS", "AMOUNT", "CURRENCY", "DESTINATION", "MESSAGE"}}
+	for _, txn := range *txns {
+		created := ""
+		if txn.CreatedAt != nil {
+			created = txn.CreatedAt.Format(time.RFC3339)
+		}
+
+		var amt, cur string
+		if d := txn.Denomination; d != nil {
+			amt, cur = amount(d.Amount), d.Currency
+		}
+
+		dest := txn.Destination.CardID
+		if dest == "" {
+			dest = txn.Destination.Description
+		}
+
+		t.rows = append(t.rows, []string{
+			txn.ID, created, string(txn.Type), string(txn.Status), amt, cur, dest, txn.Message,
+		})
+	}
+
+	if *output == "" {
+		return render(e.out, e.format, t, txns)
+	}
+
+	// render into memory first so an unknown format
+	// does not leave an empty file behind
+	var buf bytes.Buffer
+	if err := render(&buf, e.format, t, txns); err != nil {
+		return err
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if _, err := buf.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runTicker(e *env, args []string) error {
+	fs := newFlagSet(e, "ticker")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	var pairs *[]uphold.CurrencyPair
+	if fs.NArg() > 0 {
+		pairs, _, err = c.Ticker.List(uphold.CurrencyCode(strings.ToUpper(fs.Arg(0))))
+	} else {
+		pairs, _, err = c.Ticker.ListAll()
+	}
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"PAIR", "ASK", "BID", "CURRENCY"}}
+	for _, p := range *pairs {
+		t.rows = append(t.rows, []string{p.Pair, amount(p.Ask), amount(p.Bid), p.Currency})
+	}
+	return render(e.out, e.format, t, pairs)
+}
+
+func runTransfer(e *env, args []string) error {
+	fs := newFlagSet(e, "transfer")
+	from := fs.String("from", "", "origin card `id`")
+	to := fs.String("to", "", "destination card id, email or crypto address")
+	amt := fs.Float64("amount", 0, "amount to transfer")
+	currency := fs.String("currency", "", "currency of the amount, defaults to the origin card currency")
+	message := fs.String("message", "", "message attached to the transaction")
+	yes := fs.Bool("yes", false, "commit without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" || *amt <= 0 {
+		return errors.New("-from, -to and a positive -amount are required")
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	card, _, err := c.Card.List(*from)
+	if err != nil {
+		return err
+	}
+	if *currency == "" {
+		*currency = card.Currency
+	}
+
+	q := uphold.Quote{
+		Denomination: &uphold.QuoteDenomination{
+			Amount:   float32(*amt),
+			Currency: uphold.CurrencyCode(strings.ToUpper(*currency)),
+		},
+		Destination: *to,
+	}
+
+	txn, _, err := c.Transaction.Create(*card, q)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.err, "Send %s %s from %q to %s",
+		amount(txn.Origin.Amount), txn.Origin.Currency, card.Label, *to)
+	if txn.Destination.Currency != "" {
+		fmt.Fprintf(e.err, ", receiving %s %s", amount(txn.Destination.Amount), txn.Destination.Currency)
+	}
+	fmt.Fprintln(e.err)
+
+	if !*yes {
+		ok, err := e.confirm("Commit this transaction?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if _, _, err := c.Transaction.Cancel(*card, *txn); err != nil {
+				return fmt.Errorf("transaction not committed, cancelling the quote failed: %s", err)
+			}
+			return errors.New("transaction not committed")
+		}
+	}
+
+	txn, _, err = c.Transaction.Commit(*card, *txn, *message)
+	if err != nil {
+		return err
+	}
+
+	t := table{
+		header: []string{"ID", "STATUS", "AMOUNT", "CURRENCY"},
+		rows:   [][]string{{txn.ID, string(txn.Status), amount(txn.Origin.Amount), txn.Origin.Currency}},
+	}
+	return render(e.out, e.format, t, txn)
+}
+
+func runContacts(e *env, args []string) error {
+	if len(args) > 0 && args[0] == "add" {
+		return runContactsAdd(e, args[1:])
+	}
+	if len(args) > 0 && args[0] == "list" {
+		args = args[1:]
+	}
+	if err := newFlagSet(e, "contacts").Parse(args); err != nil {
+		return err
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	contacts, _, err := c.Contact.ListAll()
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"ID", "NAME", "COMPANY", "EMAILS", "ADDRESSES"}}
+	for _, ct := range *contacts {
+		t.rows = append(t.rows, []string{
+			ct.ID, ct.Name, ct.Company, strings.Join(ct.Emails, " "), strings.Join(ct.Addresses, " "),
+		})
+	}
+	return render(e.out, e.format, t, contacts)
+}
+
+func runContactsAdd(e *env, args []string) error {
+	fs := newFlagSet(e, "contacts add")
+	first := fs.String("first", "", "first name")
+	last := fs.String("last", "", "last name")
+	company := fs.String("company", "", "company name")
+	email := fs.String("email", "", "email address")
+	address := fs.String("address", "", "crypto address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	n := uphold.Contact{FirstName: *first, LastName: *last, Company: *company}
+	if *email != "" {
+		n.Emails = []string{*email}
+	}
+	if *address != "" {
+		n.Addresses = []string{*address}
+	}
+
+	c, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	contact, _, err := c.Contact.Add(n)
+	if err != nil {
+		return err
+	}
+
+	t := table{
+		header: []string{"ID", "NAME", "COMPANY"},
+		rows:   [][]string{{contact.ID, contact.Name, contact.Company}},
+	}
+	return render(e.out, e.format, t, contact)
+}
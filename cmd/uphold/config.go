@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gufran/uphold"
+	"golang.org/x/oauth2"
+)
+
+// defaultRedirectURL is where the local login server
+// listens unless the profile configures another URL
+const defaultRedirectURL = "http://localhost:8910/callback"
+
+// Config is the content of the configuration file
+type Config struct {
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// Profile holds the settings for one Uphold account or application
+type Profile struct {
+	ClientID     string   `json:"clientId,omitempty"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	RedirectURL  string   `json:"redirectUrl,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	Sandbox      bool     `json:"sandbox,omitempty"`
+
+	// AccessToken is a personal access token used instead
+	// of the token stored by the login command
+	AccessToken string `json:"accessToken,omitempty"`
+
+	// APIURL overrides the API endpoint, for
+	// instance to use a proxy or a fake server
+	APIURL string `json:"apiUrl,omitempty"`
+}
+
+// defaultConfigDir returns the directory holding the configuration
+// file and the stored tokens, $HOME/.uphold unless UPHOLD_CONFIG_DIR
+// is set
+func defaultConfigDir() string {
+	if dir := os.Getenv("UPHOLD_CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".uphold")
+}
+
+// loadConfig reads the configuration file from dir.
+// A missing file results in an empty configuration.
+func loadConfig(dir string) (*Config, error) {
+	conf := &Config{Profiles: map[string]Profile{}}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return conf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, conf); err != nil {
+		return nil, fmt.Errorf("invalid configuration file: %s", err)
+	}
+	return conf, nil
+}
+
+// oauthConfig returns the OAuth configuration for the profile
+func (p Profile) oauthConfig() *oauth2.Config {
+	t := uphold.Terminals{
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  uphold.LiveAuthURL,
+			TokenURL: uphold.TokenAccessURL,
+		},
+		RedirectURL: p.RedirectURL,
+	}
+	if p.Sandbox {
+		t.AuthURL = uphold.SandBoxAuthURL
+		t.TokenURL = uphold.SandboxTokenAccessURL
+	}
+	if t.RedirectURL == "" {
+		t.RedirectURL = defaultRedirectURL
+	}
+
+	scopes := []uphold.Permission{
+		uphold.PermissionUserRead,
+		uphold.PermissionAccountsRead,
+		uphold.PermissionCardsRead,
+		uphold.PermissionCardsWrite,
+		uphold.PermissionContactsRead,
+		uphold.PermissionContactsWrite,
+		uphold.PermissionTransactionsRead,
+		uphold.PermissionTransactionsTransferSelf,
+		uphold.PermissionTransactionsTransferOthers,
+	}
+	if len(p.Scopes) > 0 {
+		scopes = nil
+		for _, s := range p.Scopes {
+			scopes = append(scopes, uphold.Permission(s))
+		}
+	}
+
+	cred := uphold.Credential{ClientID: p.ClientID, ClientSecret: p.ClientSecret}
+	return uphold.ConfigureOAuth(cred, t, scopes)
+}
+
+// tokenKeyEnv names the environment variable holding the hex encoded
+// key of the stored tokens, used instead of the key file when set
+const tokenKeyEnv = "UPHOLD_TOKEN_KEY"
+
+// tokenKey returns the key encrypting the stored tokens, read from
+// UPHOLD_TOKEN_KEY or from the token.key file in dir. A missing key
+// file is created with a random key if create is set, otherwise
+// uphold.ErrNoToken is returned since no token can have been saved.
+func tokenKey(dir string, create bool) ([]byte, error) {
+	if s := os.Getenv(tokenKeyEnv); s != "" {
+		key, err := hex.DecodeString(s)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%s must hold 32 hex encoded bytes", tokenKeyEnv)
+		}
+		return key, nil
+	}
+
+	path := filepath.Join(dir, "token.key")
+	b, err := ioutil.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(b)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid token key file %s", path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !create {
+		return nil, uphold.ErrNoToken
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintln(f, hex.EncodeToString(key)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return key, f.Close()
+}
@@ -0,0 +1,205 @@
+// Command uphold is a command line client for everyday operations on an
+// Uphold account: listing cards, balances, transactions and tickers,
+// transferring funds and managing contacts.
+//
+// Usage:
+//
+//	uphold [flags] <command> [arguments]
+//
+// Settings are read from the profiles in $HOME/.uphold/config.json:
+//
+//	{
+//	  "profiles": {
+//	    "default": {
+//	      "clientId": "<client id>",
+//	      "clientSecret": "<client secret>",
+//	      "sandbox": true
+//	    }
+//	  }
+//	}
+//
+// Run "uphold login" to authorize the application and store the token.
+// Tokens are encrypted with a key generated in $HOME/.uphold/token.key,
+// or with the hex encoded key in UPHOLD_TOKEN_KEY if set.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gufran/uphold"
+	"golang.org/x/oauth2"
+)
+
+// command is a subcommand of the tool
+type command struct {
+	name  string
+	usage string
+	run   func(e *env, args []string) error
+}
+
+var commands = []command{
+	{"login", "authorize the application and store the token", runLogin},
+	{"cards", "list cards", runCards},
+	{"balances", "show card balances", runBalances},
+	{"transactions", "list or export transactions", runTransactions},
+	{"ticker", "show exchange rates", runTicker},
+	{"transfer", "create and commit a transfer", runTransfer},
+	{"contacts", "list or add contacts", runContacts},
+}
+
+// env is the environment shared by all commands
+type env struct {
+	dir         string
+	profileName string
+	profile     Profile
+	format      string
+
+	in  *bufio.Reader
+	out io.Writer
+	err io.Writer
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("uphold", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	e := &env{in: bufio.NewReader(stdin), out: stdout, err: stderr}
+	fs.StringVar(&e.dir, "config", defaultConfigDir(), "configuration `directory`")
+	fs.StringVar(&e.profileName, "profile", "default", "configuration profile to use")
+	fs.StringVar(&e.format, "format", formatTable, "output format: table, json or csv")
+	sandbox := fs.Bool("sandbox", false, "use the sandbox environment")
+
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: uphold [flags] <command> [arguments]\n\nCommands:")
+		for _, c := range commands {
+			fmt.Fprintf(stderr, "  %-14s %s\n", c.name, c.usage)
+		}
+		fmt.Fprintln(stderr, "\nFlags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	conf, err := loadConfig(e.dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "uphold: %s\n", err)
+		return 1
+	}
+	e.profile = conf.Profiles[e.profileName]
+	if *sandbox {
+		e.profile.Sandbox = true
+	}
+
+	name := fs.Arg(0)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		if err := c.run(e, fs.Args()[1:]); err != nil {
+			fmt.Fprintf(stderr, "uphold %s: %s\n", name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "uphold: unknown command %q\n", name)
+	fs.Usage()
+	return 2
+}
+
+// tokenPath returns the file holding the token of the current profile
+func (e *env) tokenPath() string {
+	return filepath.Join(e.dir, e.profileName+".token")
+}
+
+// tokenStore returns the encrypted store holding the token of the
+// current profile, creating its key if create is set
+func (e *env) tokenStore(create bool) (*uphold.FileTokenStore, error) {
+	key, err := tokenKey(e.dir, create)
+	if err != nil {
+		return nil, err
+	}
+	return uphold.NewFileTokenStore(e.tokenPath(), key)
+}
+
+// client returns an Uphold client authorized with the profile personal
+// access token or with the token stored by the login command. Refreshed
+// tokens are saved back to the store.
+func (e *env) client() (*uphold.Client, error) {
+	var c *uphold.Client
+
+	if e.profile.AccessToken != "" {
+		c = uphold.NewClient(nil)
+		c.SetPersonalAccessToken(e.profile.AccessToken)
+	} else {
+		store, err := e.tokenStore(false)
+		if err == nil {
+			_, err = store.Load()
+		}
+		if err != nil {
+			if err == uphold.ErrNoToken {
+				return nil, errors.New("not logged in, run `uphold login` first")
+			}
+			return nil, err
+		}
+
+		src := uphold.NewTokenSource(e.profile.oauthConfig(), store)
+		c = uphold.NewClient(oauth2.NewClient(oauth2.NoContext, src))
+	}
+
+	if e.profile.Sandbox {
+		c.UseSandbox()
+	}
+	if e.profile.APIURL != "" {
+		if err := c.SetAPIURL(e.profile.APIURL); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// confirm asks the user a yes or no question, defaulting to no
+func (e *env) confirm(question string) (bool, error) {
+	fmt.Fprintf(e.err, "%s [y/N] ", question)
+
+	line, err := e.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// sortedKeys returns the keys of m in lexical order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gufran/uphold"
+	"github.com/gufran/uphold/upholdtest"
+	"golang.org/x/oauth2"
+)
+
+// setupCLI writes a configuration pointing at a fake server and
+// returns a function running the tool with given input and flags
+func setupCLI(t *testing.T, s *upholdtest.Server) (func(stdin string, args ...string) (string, int), func()) {
+	dir, err := ioutil.TempDir("", "uphold-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{Profiles: map[string]Profile{
+		"default": {AccessToken: "token", APIURL: s.URL + upholdtest.APIPrefix},
+	}}
+	b, _ := json.Marshal(conf)
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	exec := func(stdin string, args ...string) (string, int) {
+		var out, errOut bytes.Buffer
+		args = append([]string{"-config", dir}, args...)
+		code := run(args, strings.NewReader(stdin), &out, &errOut)
+		if code != 0 {
+			t.Logf("uphold %v: %s", args, errOut.String())
+		}
+		return out.String(), code
+	}
+	return exec, func() { os.RemoveAll(dir) }
+}
+
+func TestCards(t *testing.T) {
+	s := upholdtest.NewServer()
+	defer s.Close()
+	s.AddCard(uphold.Card{ID: "c1", Label: "Savings", Currency: "USD", Balance: 12.5, Available: 12.5})
+	s.AddCard(uphold.Card{ID: "c2", Label: "Empty", Currency: "BTC"})
+
+	exec, cleanup := setupCLI(t, s)
+	defer cleanup()
+
+	out, code := exec("", "cards")
+	if code != 0 {
+		t.Fatalf("cards exited with %d", code)
+	}
+	if !strings.Contains(out, "Savings") || !strings.Contains(out, "Empty") {
+		t.Errorf("cards output is missing cards:\n%s", out)
+	}
+
+	out, code = exec("", "-format", "csv", "balances")
+	if code != 0 {
+		t.Fatalf("balances exited with %d", code)
+	}
+	want := "ID,LABEL,CURRENCY,BALANCE,AVAILABLE,NORMALIZED\nc1,Savings,USD,12.5,12.5,\n"
+	if out != want {
+		t.Errorf("balances output is %q, want %q", out, want)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	s := upholdtest.NewServer()
+	defer s.Close()
+	s.AddCard(uphold.Card{ID: "from", Label: "USD", Currency: "USD", Balance: 10, Available: 10})
+	s.AddCard(uphold.Card{ID: "to", Label: "Other", Currency: "USD"})
+
+	exec, cleanup := setupCLI(t, s)
+	defer cleanup()
+
+	if _, code := exec("n\n", "transfer", "-from", "from", "-to", "to", "-amount", "4"); code == 0 {
+		t.Error("declined transfer should exit with an error")
+	}
+	if c, _ := s.Card("to"); c.Balance != 0 {
+		t.Errorf("declined transfer moved funds, balance is %v", c.Balance)
+	}
+	if txns := s.Transactions(); len(txns) != 1 || txns[0].Status != uphold.TxnStatusCancelled {
+		t.Errorf("declined transfer left the quote %+v, want it cancelled", txns)
+	}
+
+	out, code := exec("y\n", "-format", "json", "transfer", "-from", "from", "-to", "to", "-amount", "4")
+	if code != 0 {
+		t.Fatalf("transfer exited with %d", code)
+	}
+
+	txn := new(uphold.Txn)
+	if err := json.Unmarshal([]byte(out), txn); err != nil {
+		t.Fatalf("transfer output is not JSON: %v\n%s", err, out)
+	}
+	if got, want := txn.Status, uphold.TxnStatus(uphold.TxnStatusCompleted); got != want {
+		t.Errorf("transfer status is %v, want %v", got, want)
+	}
+	if c, _ := s.Card("to"); c.Balance != 4 {
+		t.Errorf("destination balance is %v, want 4", c.Balance)
+	}
+}
+
+func TestNotLoggedIn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uphold-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var out, errOut bytes.Buffer
+	if code := run([]string{"-config", dir, "cards"}, strings.NewReader(""), &out, &errOut); code != 1 {
+		t.Errorf("cards exited with %d, want 1", code)
+	}
+	if !strings.Contains(errOut.String(), "uphold login") {
+		t.Errorf("error output does not suggest login: %s", errOut.String())
+	}
+}
+
+func TestTransactionsUnknownFormat(t *testing.T) {
+	s := upholdtest.NewServer()
+	defer s.Close()
+
+	exec, cleanup := setupCLI(t, s)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "uphold-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "txns.xml")
+	if _, code := exec("", "-format", "xml", "transactions", "-o", path); code == 0 {
+		t.Error("unknown format should exit with an error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("unknown format created %s", path)
+	}
+}
+
+func TestTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uphold-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := &env{dir: dir, profileName: "default"}
+	if _, err := e.tokenStore(false); err != uphold.ErrNoToken {
+		t.Errorf("tokenStore(false) without a key returned %v, want ErrNoToken", err)
+	}
+
+	store, err := e.tokenStore(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save(&oauth2.Token{AccessToken: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(e.tokenPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("secret")) {
+		t.Error("token is stored in clear")
+	}
+
+	store, err = e.tokenStore(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok, err := store.Load(); err != nil || tok.AccessToken != "secret" {
+		t.Errorf("Load() returned %v, %v", tok, err)
+	}
+}
@@ -0,0 +1,118 @@
+package uphold
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// inflight is a GET request in progress shared by identical requests
+type inflight struct {
+	done     chan struct{}
+	body     []byte
+	resp     *Response
+	err      error
+	panicked interface{}
+}
+
+// detachedContext keeps the values of its parent but is never
+// cancelled, so the caller which started a shared request cannot
+// cancel it for the others
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// CoalesceMiddleware deduplicates identical GET requests in flight: while
+// a request is waiting for its response, identical requests wait for the
+// same response instead of being sent. Each caller decodes its own copy
+// of the response body, so the returned values can be modified safely.
+//
+// The shared request is sent with a context detached from the caller
+// which started it: any caller may stop waiting when its context is
+// done without failing the others.
+//
+// Requests are identical when they have the same URL and Authorization
+// header. A middleware must not be shared by clients of different users
+// authenticated by their transport, such as OAuth clients.
+func CoalesceMiddleware() Middleware {
+	return coalesceMiddleware(nil)
+}
+
+// coalesceMiddleware is CoalesceMiddleware calling
+// joined, if set, when a request joins one in flight
+func coalesceMiddleware(joined func()) Middleware {
+	var mu sync.Mutex
+	calls := map[string]*inflight{}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			if req.Method != "GET" {
+				return next.Do(req, v)
+			}
+
+			key := req.URL.String() + "\x00" + req.Header.Get("Authorization")
+
+			mu.Lock()
+			c, ok := calls[key]
+			if !ok {
+				c = &inflight{done: make(chan struct{})}
+				calls[key] = c
+			}
+			mu.Unlock()
+
+			if !ok {
+				shared := req.WithContext(detachedContext{req.Context()})
+				go func() {
+					// release the waiters even if next panics
+					defer func() {
+						c.panicked = recover()
+						mu.Lock()
+						delete(calls, key)
+						mu.Unlock()
+						close(c.done)
+					}()
+
+					buf := new(bytes.Buffer)
+					c.resp, c.err = next.Do(shared, buf)
+					c.body = buf.Bytes()
+				}()
+			} else if joined != nil {
+				joined()
+			}
+
+			select {
+			case <-c.done:
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+
+			if c.panicked != nil {
+				if !ok {
+					// the panic belongs to the caller which sent the request
+					panic(c.panicked)
+				}
+				return nil, fmt.Errorf("uphold: coalesced request failed: %v", c.panicked)
+			}
+
+			var resp *Response
+			if c.resp != nil {
+				r := *c.resp
+				hr := *c.resp.Response
+				r.Response = &hr
+				resp = &r
+			}
+			if c.err != nil {
+				return resp, c.err
+			}
+			return resp, decodeBody(c.body, v)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package uphold
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCoalesceMiddleware(t *testing.T) {
+	setup()
+	defer teardown()
+
+	const n = 5
+
+	// the handler answers once every other caller joined the request
+	var calls int32
+	joined := make(chan struct{}, n)
+	mux.HandleFunc("/ticker/USD", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		for i := 0; i < n-1; i++ {
+			<-joined
+		}
+		fmt.Fprint(w, `[{"pair":"BTCUSD","ask":"1"}]`)
+	})
+
+	client.Use(coalesceMiddleware(func() { joined <- struct{}{} }))
+
+	results := make([]*[]CurrencyPair, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pairs, _, err := client.Ticker.List(CurrencyUSD)
+			if err != nil {
+				t.Errorf("Ticker.List() returned unexpected error: %v", err)
+				return
+			}
+			results[i] = pairs
+		}(i)
+	}
+
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("ticker was requested %d times, want 1", got)
+	}
+
+	(*results[0])[0].Pair = "modified"
+	for i := 1; i < n; i++ {
+		if r := results[i]; r == nil || (*r)[0].Pair != "BTCUSD" {
+			t.Errorf("result %d is %+v, want an unmodified BTCUSD pair", i, r)
+		}
+	}
+}
+
+func TestCoalesceMiddlewareLeaderCancelled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	mux.HandleFunc("/ticker/USD", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-proceed
+		fmt.Fprint(w, `[{"pair":"BTCUSD","ask":"1"}]`)
+	})
+
+	joined := make(chan struct{}, 1)
+	client.Use(coalesceMiddleware(func() { joined <- struct{}{} }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, _, err := client.WithContext(ctx).Ticker.List(CurrencyUSD)
+		leader <- err
+	}()
+	<-started
+
+	follower := make(chan error, 1)
+	go func() {
+		_, _, err := client.Ticker.List(CurrencyUSD)
+		follower <- err
+	}()
+	<-joined
+
+	cancel()
+	if err := <-leader; err != context.Canceled {
+		t.Errorf("cancelled leader returned %v, want context.Canceled", err)
+	}
+	close(proceed)
+
+	if err := <-follower; err != nil {
+		t.Errorf("follower returned %v after the leader was cancelled", err)
+	}
+}
+
+func TestCoalesceMiddlewarePanic(t *testing.T) {
+	setup()
+	defer teardown()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	panicking := func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}
+	joined := make(chan struct{}, 1)
+	client.Use(coalesceMiddleware(func() { joined <- struct{}{} }), panicking)
+
+	leader := make(chan interface{}, 1)
+	go func() {
+		defer func() { leader <- recover() }()
+		client.Ticker.List(CurrencyUSD)
+	}()
+	<-started
+
+	follower := make(chan error, 1)
+	go func() {
+		_, _, err := client.Ticker.List(CurrencyUSD)
+		follower <- err
+	}()
+	<-joined
+	close(release)
+
+	if err := <-follower; err == nil {
+		t.Error("follower of a panicking request returned no error")
+	}
+	if r := <-leader; r != "boom" {
+		t.Errorf("leader recovered %v, want boom", r)
+	}
+}
+
+func TestCoalesceMiddlewareSequential(t *testing.T) {
+	setup()
+	defer teardown()
+
+	calls := 0
+	mux.HandleFunc("/ticker", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `[]`)
+	})
+
+	client.Use(CoalesceMiddleware())
+	client.Ticker.ListAll()
+	client.Ticker.ListAll()
+
+	if calls != 2 {
+		t.Errorf("ticker was requested %d times, want 2", calls)
+	}
+}
@@ -9,7 +9,7 @@ type ContactService struct {
 
 // ListAll contacts for a user
 func (c *ContactService) ListAll() (*[]Contact, *Response, error) {
-	req, err := c.client.NewRequest("GET", "me/contacts", nil)
+	req, err := c.client.newRequest(OperationContactListAll, "", "GET", "me/contacts", nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -27,7 +27,7 @@ func (c *ContactService) ListAll() (*[]Contact, *Response, error) {
 func (c *ContactService) List(ID string) (*Contact, *Response, error) {
 	rel := fmt.Sprintf("me/contacts/%s", ID)
 
-	req, err := c.client.NewRequest("GET", rel, nil)
+	req, err := c.client.newRequest(OperationContactList, "", "GET", rel, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -40,3 +40,27 @@ func (c *ContactService) List(ID string) (*Contact, *Response, error) {
 
 	return contact, resp, nil
 }
+
+// Add a new contact to user account
+func (c *ContactService) Add(n Contact) (*Contact, *Response, error) {
+	payload := new(Contact)
+	payload.FirstName = n.FirstName
+	payload.LastName = n.LastName
+	payload.Company = n.Company
+	payload.Emails = n.Emails
+	payload.Addresses = n.Addresses
+
+	req, err := c.client.newRequest(OperationContactAdd, "", "POST", "me/contacts", payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contact := new(Contact)
+	resp, err := c.client.Do(req, contact)
+	if err != nil {
+		return nil, resp, err
+	}
+	contact.Simulated = RequestSimulated(req)
+
+	return contact, resp, nil
+}
@@ -0,0 +1,48 @@
+package uphold
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestContactAdd(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/contacts", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		want := `{"firstName":"Jane","lastName":"Doe","emails":["jane@example.com"]}`
+		testBody(t, r, want)
+
+		fmt.Fprint(w, `{
+  "id": "9fae84eb-712d-4b6a-9b2c-764bdde4c079",
+  "firstName": "Jane",
+  "lastName": "Doe",
+  "name": "Jane Doe",
+  "emails": ["jane@example.com"]
+}`)
+	})
+
+	contact, _, err := client.Contact.Add(Contact{
+		ID:        "ignored",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Emails:    []string{"jane@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Contact.Add() returned unexpected error: %v", err)
+	}
+
+	want := &Contact{
+		ID:        "9fae84eb-712d-4b6a-9b2c-764bdde4c079",
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Name:      "Jane Doe",
+		Emails:    []string{"jane@example.com"},
+	}
+	if !reflect.DeepEqual(contact, want) {
+		t.Errorf("Contact.Add() returned %+v, want %+v", contact, want)
+	}
+}
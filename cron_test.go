@@ -0,0 +1,45 @@
+package uphold
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2016, 10, 12, 10, 30, 0, 0, time.UTC) // a Wednesday
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2016, 10, 12, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * MON", time.Date(2016, 10, 17, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2016, 10, 13, 9, 0, 0, 0, time.UTC)},
+		{"30 10 1,15 * *", time.Date(2016, 10, 15, 10, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 0", time.Date(2016, 10, 16, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2016, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2016, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2016, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90m", time.Date(2016, 10, 12, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) returned unexpected error: %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("ParseSchedule(%q).Next() returned %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleInvalid(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@sometimes"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("ParseSchedule(%q) should return an error", spec)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package uphold
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+)
+
+// simulatedOperations are not sent to the API in dry-run mode
+var simulatedOperations = map[Operation]bool{
+	OperationCardAdd:           true,
+	OperationCardUpdate:        true,
+	OperationCardDelete:        true,
+	OperationContactAdd:        true,
+	OperationTransactionCommit: true,
+	OperationTransactionCancel: true,
+}
+
+// simulatedKey is the context key marking simulated requests
+type simulatedKey struct{}
+
+// SetDryRun turns the dry-run mode of the client on or off. In dry-run
+// mode adding, updating and deleting cards, adding contacts, and committing
+// and cancelling transactions, are validated and go through the middleware
+// but are not sent, and the results are synthesized from the request.
+// Transactions created with a realtime quote are sent without being
+// committed. Results of simulated calls have Simulated set.
+func (c *Client) SetDryRun(on bool) {
+	c.dryRun = on
+}
+
+// DryRun reports whether the client is in dry-run mode
+func (c *Client) DryRun() bool {
+	return c.dryRun
+}
+
+// RequestSimulated reports whether the request is simulated
+// by a client in dry-run mode instead of being sent
+func RequestSimulated(req *http.Request) bool {
+	s, _ := req.Context().Value(simulatedKey{}).(bool)
+	return s
+}
+
+// markSimulated marks the request as simulated if it
+// would change anything and the client is in dry-run mode
+func (c *Client) markSimulated(op Operation, req *http.Request) *http.Request {
+	if !c.dryRun || !simulatedOperations[op] {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), simulatedKey{}, true))
+}
+
+// simulate answers a simulated request without sending it,
+// decoding the request body into v as the API would echo it
+func (c *Client) simulate(req *http.Request, v interface{}) (*Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	if v != nil && len(body) > 0 {
+		if err := json.Unmarshal(body, v); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+		Request:    req,
+	}
+	return &Response{Response: resp}, nil
+}
@@ -0,0 +1,105 @@
+package uphold
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDryRunCards(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry-run sent %s %s", r.Method, r.URL.Path)
+	})
+	mux.HandleFunc("/me/cards/c1", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry-run sent %s %s", r.Method, r.URL.Path)
+	})
+
+	l := new(testLogger)
+	client.Use(LoggingMiddleware(l, LogSummary))
+	client.SetDryRun(true)
+
+	card, _, err := client.Card.Add(Card{Label: "savings", Currency: "EUR"})
+	if err != nil {
+		t.Fatalf("Card.Add() returned unexpected error: %v", err)
+	}
+	if !card.Simulated || card.Label != "savings" || card.Currency != "EUR" {
+		t.Errorf("Card.Add() returned %+v, want a simulated EUR card", card)
+	}
+
+	card, _, err = client.Card.Update(Card{ID: "c1", Label: "spending", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("Card.Update() returned unexpected error: %v", err)
+	}
+	if !card.Simulated || card.ID != "c1" || card.Label != "spending" {
+		t.Errorf("Card.Update() returned %+v, want the simulated card c1", card)
+	}
+
+	if len(l.entries) != 2 || !strings.Contains(l.entries[0], "simulated=true") {
+		t.Errorf("logged %q, want two simulated requests", l.entries)
+	}
+}
+
+func TestDryRunContact(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/contacts", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry-run sent %s %s", r.Method, r.URL.Path)
+	})
+	client.SetDryRun(true)
+
+	contact, _, err := client.Contact.Add(Contact{FirstName: "Jane", Emails: []string{"jane@example.com"}})
+	if err != nil {
+		t.Fatalf("Contact.Add() returned unexpected error: %v", err)
+	}
+	if !contact.Simulated || contact.FirstName != "Jane" {
+		t.Errorf("Contact.Add() returned %+v, want a simulated contact", contact)
+	}
+}
+
+func TestDryRunTransactions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if r.URL.Query().Get("commit") != "" {
+			t.Errorf("dry-run committed a realtime quote")
+		}
+		fmt.Fprint(w, `{"id":"t1","status":"pending"}`)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/t1/commit", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry-run sent %s %s", r.Method, r.URL.Path)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/t1/cancel", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("dry-run sent %s %s", r.Method, r.URL.Path)
+	})
+	client.SetDryRun(true)
+
+	card := Card{ID: "c1"}
+	q := Quote{Denomination: &QuoteDenomination{Amount: 10, Currency: CurrencyUSD}, Destination: "c2"}
+
+	txn, _, err := client.Transaction.Create(card, q)
+	if err != nil {
+		t.Fatalf("Create() returned unexpected error: %v", err)
+	}
+	if txn.Simulated || txn.Status != TxnStatusPending {
+		t.Errorf("Create() returned %+v, want a real pending quote", txn)
+	}
+
+	q.Realtime = true
+	if r, _, err := client.Transaction.Create(card, q); err != nil || !r.Simulated || r.Status != TxnStatusCompleted {
+		t.Errorf("realtime Create() returned %+v, %v, want a simulated completed transaction", r, err)
+	}
+
+	if r, _, err := client.Transaction.Commit(card, *txn, "thanks"); err != nil || !r.Simulated || r.ID != "t1" || r.Status != TxnStatusCompleted {
+		t.Errorf("Commit() returned %+v, %v, want a simulated completed transaction", r, err)
+	}
+	if r, _, err := client.Transaction.Cancel(card, *txn); err != nil || !r.Simulated || r.Status != TxnStatusCancelled {
+		t.Errorf("Cancel() returned %+v, %v, want a simulated cancelled transaction", r, err)
+	}
+}
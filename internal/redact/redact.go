@@ -0,0 +1,116 @@
+// Package redact removes secrets and personal data from the requests
+// and responses of the Uphold API, for the logs and the test cassettes
+package redact
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+)
+
+// Redacted replaces the redacted values
+const Redacted = "REDACTED"
+
+// Headers which are never kept in clear
+var Headers = []string{
+	"Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"OTP-Token",
+}
+
+// Fields of the bodies whose values are never kept in clear: tokens,
+// passwords, crypto addresses of cards and contacts, and personal data
+var Fields = map[string]bool{
+	"access_token":        true,
+	"accessToken":         true,
+	"address":             true,
+	"addresses":           true,
+	"birthdate":           true,
+	"e164Masked":          true,
+	"email":               true,
+	"emails":              true,
+	"firstName":           true,
+	"internationalMasked": true,
+	"lastName":            true,
+	"name":                true,
+	"nationalMasked":      true,
+	"password":            true,
+	"phone":               true,
+	"phones":              true,
+	"refresh_token":       true,
+	"username":            true,
+}
+
+// StringFields are redacted only when they hold a string, such as the
+// destination of a quote which may be a crypto address or an email,
+// while the destination of a transaction is an object with its amounts
+var StringFields = map[string]bool{
+	"destination": true,
+}
+
+// EmailPattern matches email addresses anywhere in a value
+var EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// emailOnly matches values which are a single email address
+var emailOnly = regexp.MustCompile(`^` + EmailPattern.String() + `$`)
+
+// Header returns a copy of h with the secret headers redacted
+func Header(h http.Header) http.Header {
+	c := http.Header{}
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+
+	for _, k := range Headers {
+		if c.Get(k) != "" {
+			c.Set(k, Redacted)
+		}
+	}
+	return c
+}
+
+// Body returns a JSON body with the secret fields redacted and the email
+// addresses, in secret fields or elsewhere, replaced by email. Bodies which are not JSON
+// only have email addresses replaced.
+func Body(b []byte, email string) string {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return EmailPattern.ReplaceAllString(string(b), email)
+	}
+
+	out, err := json.Marshal(value(v, false, email))
+	if err != nil {
+		return EmailPattern.ReplaceAllString(string(b), email)
+	}
+	return string(out)
+}
+
+// value redacts the secret fields of v, or every string in v if all is
+// true, keeping the shape of objects and arrays intact
+func value(v interface{}, all bool, email string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			_, isString := e.(string)
+			t[k] = value(e, all || Fields[k] || (StringFields[k] && isString), email)
+		}
+		return t
+	case []interface{}:
+		for n, e := range t {
+			t[n] = value(e, all, email)
+		}
+		return t
+	case string:
+		if all && emailOnly.MatchString(t) {
+			// keep email fields valid emails
+			return email
+		}
+		if all {
+			return Redacted
+		}
+		return EmailPattern.ReplaceAllString(t, email)
+	default:
+		return v
+	}
+}
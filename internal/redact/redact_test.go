@@ -0,0 +1,44 @@
+package redact
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBody(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{
+			`{"firstName":"Jane","lastName":"Doe","emails":["jane@example.com"],"company":"ACME"}`,
+			`{"company":"ACME","emails":["x@example.com"],"firstName":"REDACTED","lastName":"REDACTED"}`,
+		},
+		{
+			`{"denomination":{"amount":"1","currency":"BTC"},"destination":"1BoatSLRHtKNngkdXEeobR76b53LETtpyT"}`,
+			`{"denomination":{"amount":"1","currency":"BTC"},"destination":"REDACTED"}`,
+		},
+		{
+			`{"id":"t1","destination":{"amount":"1","currency":"BTC"},"message":"to jane@example.com"}`,
+			`{"destination":{"amount":"1","currency":"BTC"},"id":"t1","message":"to x@example.com"}`,
+		},
+		{`not json jane@example.com`, `not json x@example.com`},
+	}
+
+	for _, tt := range tests {
+		if got := Body([]byte(tt.in), "x@example.com"); got != tt.want {
+			t.Errorf("Body(%s) returned %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	h := http.Header{"Authorization": {"Bearer secret"}, "Accept": {"application/json"}}
+
+	got := Header(h)
+	if got.Get("Authorization") != Redacted || got.Get("Accept") != "application/json" {
+		t.Errorf("Header() returned %v", got)
+	}
+	if h.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Header() modified its argument")
+	}
+}
@@ -0,0 +1,96 @@
+package uphold
+
+// keccak256 returns the legacy Keccak-256 hash of data, as used by
+// ethereum. It differs from SHA3-256 by its padding only.
+func keccak256(data []byte) [32]byte {
+	const rate = 136
+
+	var state [25]uint64
+	absorb := func(block []byte) {
+		for i := 0; i < rate/8; i++ {
+			state[i] ^= uint64(block[8*i]) | uint64(block[8*i+1])<<8 | uint64(block[8*i+2])<<16 | uint64(block[8*i+3])<<24 |
+				uint64(block[8*i+4])<<32 | uint64(block[8*i+5])<<40 | uint64(block[8*i+6])<<48 | uint64(block[8*i+7])<<56
+		}
+		keccakF1600(&state)
+	}
+
+	for len(data) >= rate {
+		absorb(data[:rate])
+		data = data[rate:]
+	}
+
+	last := make([]byte, rate)
+	copy(last, data)
+	last[len(data)] ^= 0x01
+	last[rate-1] ^= 0x80
+	absorb(last)
+
+	var sum [32]byte
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			sum[8*i+j] = byte(state[i] >> uint(8*j))
+		}
+	}
+	return sum
+}
+
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations are the rotation offsets of the lanes, indexed x+5y
+var keccakRotations = [25]uint{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// keccakF1600 applies the Keccak-f[1600] permutation to the state
+func keccakF1600(a *[25]uint64) {
+	rotl := func(v uint64, n uint) uint64 {
+		return v<<n | v>>(64-n)
+	}
+
+	for round := 0; round < 24; round++ {
+		// theta
+		var c, d [5]uint64
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d[x] = c[(x+4)%5] ^ rotl(c[(x+1)%5], 1)
+		}
+		for i := range a {
+			a[i] ^= d[i%5]
+		}
+
+		// rho and pi
+		var b [25]uint64
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				v := a[x+5*y]
+				if r := keccakRotations[x+5*y]; r != 0 {
+					v = rotl(v, r)
+				}
+				b[y+5*((2*x+3*y)%5)] = v
+			}
+		}
+
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[y+x] = b[y+x] ^ (^b[y+(x+1)%5] & b[y+(x+2)%5])
+			}
+		}
+
+		// iota
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
@@ -0,0 +1,98 @@
+package uphold
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gufran/uphold/internal/redact"
+)
+
+// Logger records structured messages with key value pairs,
+// as implemented by *slog.Logger
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// LogVerbosity controls what LoggingMiddleware records
+type LogVerbosity int
+
+// Verbosity levels of LoggingMiddleware, each including the previous
+const (
+	// LogSummary records method, path, operation, status,
+	// latency and rate limit of each request
+	LogSummary LogVerbosity = iota
+
+	// LogHeaders adds the request and response headers
+	LogHeaders
+
+	// LogBodies adds the request body and the decoded response
+	LogBodies
+)
+
+// LoggingMiddleware records every request to l once the response is
+// received, at Info level if it succeeded and Error level otherwise.
+// Authorization, OTP tokens, crypto addresses and personal data such as
+// names and emails are redacted.
+func LoggingMiddleware(l Logger, verbosity LogVerbosity) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			args := []interface{}{
+				"method", req.Method,
+				"path", req.URL.Path,
+			}
+			if op := RequestOperation(req); op != "" {
+				args = append(args, "operation", op.String())
+			}
+			if RequestSimulated(req) {
+				args = append(args, "simulated", true)
+			}
+			if verbosity >= LogHeaders {
+				args = append(args, "request_headers", redact.Header(req.Header))
+			}
+			if verbosity >= LogBodies && req.Body != nil {
+				body, err := ioutil.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+				args = append(args, "request_body", redact.Body(body, redact.Redacted))
+			}
+
+			start := time.Now()
+			resp, err := next.Do(req, v)
+			args = append(args, "latency", time.Since(start))
+
+			if resp != nil {
+				args = append(args,
+					"status", resp.StatusCode,
+					"rate_limit", resp.Limit,
+					"rate_remaining", resp.Remaining,
+				)
+				if verbosity >= LogHeaders {
+					args = append(args, "response_headers", redact.Header(resp.Header))
+				}
+			}
+
+			if err != nil {
+				l.Error("uphold request failed", append(args, "error", err.Error())...)
+				return resp, err
+			}
+
+			if verbosity >= LogBodies && v != nil {
+				if _, ok := v.(io.Writer); !ok {
+					if body, err := json.Marshal(v); err == nil {
+						args = append(args, "response_body", redact.Body(body, redact.Redacted))
+					}
+				}
+			}
+			l.Info("uphold request", args...)
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package uphold
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// testLogger records the messages with their key value pairs
+type testLogger struct {
+	entries []string
+}
+
+func (l *testLogger) log(level, msg string, args []interface{}) {
+	e := level + " " + msg
+	for i := 0; i+1 < len(args); i += 2 {
+		e += fmt.Sprintf(" %v=%v", args[i], args[i+1])
+	}
+	l.entries = append(l.entries, e)
+}
+
+func (l *testLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args) }
+func (l *testLogger) Error(msg string, args ...interface{}) { l.log("ERROR", msg, args) }
+
+func TestLoggingMiddlewareSummary(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateLimit, "300")
+		w.Header().Set(headerRateRemaining, "299")
+		w.Write([]byte(`[]`))
+	})
+
+	l := new(testLogger)
+	client.Use(LoggingMiddleware(l, LogSummary))
+
+	if _, _, err := client.Card.ListAll(); err != nil {
+		t.Fatalf("Card.ListAll() returned unexpected error: %v", err)
+	}
+
+	if len(l.entries) != 1 {
+		t.Fatalf("logged %d entries, want 1", len(l.entries))
+	}
+	for _, want := range []string{"INFO uphold request", "method=GET", "path=/me/cards", "operation=Card.ListAll", "status=200", "rate_limit=300", "rate_remaining=299", "latency="} {
+		if !strings.Contains(l.entries[0], want) {
+			t.Errorf("log entry %q does not contain %q", l.entries[0], want)
+		}
+	}
+	if strings.Contains(l.entries[0], "headers") {
+		t.Errorf("log entry %q should not contain headers", l.entries[0])
+	}
+}
+
+func TestLoggingMiddlewareRedaction(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/contacts", func(w http.ResponseWriter, r *http.Request) {
+		testBody(t, r, `{"firstName":"Foo","emails":["foo@bar.com"]}`)
+		w.Write([]byte(`{"id":"1","firstName":"Foo","emails":["foo@bar.com"],"addresses":["1GpBtJXXa1NdG94cYPGZTc3DfRY2P7EwzH"]}`))
+	})
+
+	l := new(testLogger)
+	client.Use(LoggingMiddleware(l, LogBodies))
+	client.SetPersonalAccessToken("secret-token")
+
+	if _, _, err := client.Contact.Add(Contact{FirstName: "Foo", Emails: []string{"foo@bar.com"}}); err != nil {
+		t.Fatalf("Contact.Add() returned unexpected error: %v", err)
+	}
+
+	e := l.entries[0]
+	for _, secret := range []string{"secret-token", "foo@bar.com", "1GpBtJXXa1NdG94cYPGZTc3DfRY2P7EwzH", "Foo"} {
+		if strings.Contains(e, secret) {
+			t.Errorf("log entry %q contains %q", e, secret)
+		}
+	}
+	for _, want := range []string{"request_body=", "response_body=", `"firstName":"REDACTED"`, `"id":"1"`, "Authorization:[REDACTED]"} {
+		if !strings.Contains(e, want) {
+			t.Errorf("log entry %q does not contain %q", e, want)
+		}
+	}
+}
+
+func TestLoggingMiddlewareError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+
+	l := new(testLogger)
+	client.Use(LoggingMiddleware(l, LogSummary))
+	client.Card.ListAll()
+
+	if len(l.entries) != 1 || !strings.HasPrefix(l.entries[0], "ERROR uphold request failed") || !strings.Contains(l.entries[0], "status=403") {
+		t.Errorf("log entries are %q, want a failed request with status 403", l.entries)
+	}
+}
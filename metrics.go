@@ -0,0 +1,270 @@
+package uphold
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorClass categorizes the outcome of a request for metrics
+type ErrorClass string
+
+// Classes of request outcomes
+const (
+	ErrorClassNone        ErrorClass = "none"
+	ErrorClassClient      ErrorClass = "client_error"
+	ErrorClassServer      ErrorClass = "server_error"
+	ErrorClassRateLimited ErrorClass = "rate_limited"
+	ErrorClassDecode      ErrorClass = "decode_error"
+	ErrorClassNetwork     ErrorClass = "network_error"
+)
+
+// ClassifyError returns the class of the outcome of a request
+func ClassifyError(resp *Response, err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+
+	switch e := err.(type) {
+	case RateLimitError:
+		return ErrorClassRateLimited
+	case ErrorResponse:
+		if e.Response.StatusCode >= 500 {
+			return ErrorClassServer
+		}
+		return ErrorClassClient
+	}
+
+	if resp != nil {
+		return ErrorClassDecode
+	}
+	return ErrorClassNetwork
+}
+
+// Observation is the measurement of a single request attempt
+type Observation struct {
+	// Endpoint is the normalized endpoint, see NormalizeEndpoint
+	Endpoint string
+	Method   string
+
+	// Status is zero if the request did not reach the API
+	Status  int
+	Class   ErrorClass
+	Latency time.Duration
+
+	// Attempt is greater than 1 for retried requests
+	Attempt int
+
+	// Rate is the rate limit reported by the API, zero if unknown
+	Rate RequestRate
+}
+
+// Collector receives the measurements of API requests
+type Collector interface {
+	Observe(o Observation)
+}
+
+// MetricsMiddleware reports every request attempt to c. Add it after
+// RetryMiddleware to measure each attempt and count the retries.
+func MetricsMiddleware(c Collector) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			start := time.Now()
+			resp, err := next.Do(req, v)
+
+			o := Observation{
+				Endpoint: NormalizeEndpoint(req.URL.Path),
+				Method:   req.Method,
+				Class:    ClassifyError(resp, err),
+				Latency:  time.Since(start),
+				Attempt:  RequestAttempt(req),
+			}
+			if resp != nil {
+				o.Status = resp.StatusCode
+				o.Rate = resp.RequestRate
+			}
+
+			c.Observe(o)
+			return resp, err
+		})
+	}
+}
+
+// Collections of the API whose next path segment is an identifier
+var idCollections = map[string]string{
+	"accounts":     "{id}",
+	"cards":        "{id}",
+	"contacts":     "{id}",
+	"tokens":       "{id}",
+	"transactions": "{id}",
+	"ticker":       "{currency}",
+}
+
+// NormalizeEndpoint returns the template of an API path, replacing
+// identifiers with placeholders so that metrics are labeled by endpoint
+// rather than raw URL. For instance /v0/me/cards/<id>/transactions
+// becomes me/cards/{id}/transactions.
+func NormalizeEndpoint(path string) string {
+	path = strings.Trim(path, "/")
+	path = strings.TrimPrefix(path, "v0/")
+
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		if p, ok := idCollections[segments[i-1]]; ok {
+			segments[i] = p
+			i++
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// DefaultLatencyBuckets returns the upper bounds, in seconds, of the
+// latency histogram of Metrics. Every call returns a new slice.
+func DefaultLatencyBuckets() []float64 {
+	return []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+}
+
+// Metrics is a Collector keeping the measurements in memory
+// and exposing them in the OpenMetrics text format
+type Metrics struct {
+	mu        sync.Mutex
+	buckets   []float64
+	requests  map[string]int
+	retries   map[string]int
+	latencies map[string]*histogram
+	rates     map[string]RequestRate
+}
+
+// histogram counts the observations in cumulative buckets
+type histogram struct {
+	counts []int
+	sum    float64
+	count  int
+}
+
+// NewMetrics returns an empty Metrics using DefaultLatencyBuckets
+func NewMetrics() *Metrics {
+	return &Metrics{
+		buckets:   DefaultLatencyBuckets(),
+		requests:  map[string]int{},
+		retries:   map[string]int{},
+		latencies: map[string]*histogram{},
+		rates:     map[string]RequestRate{},
+	}
+}
+
+// Observe records a request attempt
+func (m *Metrics) Observe(o Observation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ep := labels("endpoint", o.Endpoint, "method", o.Method)
+	m.requests[labels("endpoint", o.Endpoint, "method", o.Method, "class", string(o.Class))]++
+	if o.Attempt > 1 {
+		m.retries[ep]++
+	}
+
+	h, ok := m.latencies[ep]
+	if !ok {
+		h = &histogram{counts: make([]int, len(m.buckets))}
+		m.latencies[ep] = h
+	}
+	secs := o.Latency.Seconds()
+	for i, le := range m.buckets {
+		if secs <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+
+	if o.Rate.Limit > 0 {
+		m.rates[labels("endpoint", o.Endpoint)] = o.Rate
+	}
+}
+
+// WriteTo writes the metrics in the OpenMetrics text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b bytes.Buffer
+	b.WriteString("# TYPE uphold_requests counter\n")
+	b.WriteString("# HELP uphold_requests API requests by endpoint, method and error class.\n")
+	for _, k := range sortedKeys(m.requests) {
+		fmt.Fprintf(&b, "uphold_requests_total{%s} %d\n", k, m.requests[k])
+	}
+
+	b.WriteString("# TYPE uphold_retries counter\n")
+	b.WriteString("# HELP uphold_retries Retried API requests by endpoint and method.\n")
+	for _, k := range sortedKeys(m.retries) {
+		fmt.Fprintf(&b, "uphold_retries_total{%s} %d\n", k, m.retries[k])
+	}
+
+	b.WriteString("# TYPE uphold_request_duration_seconds histogram\n")
+	b.WriteString("# HELP uphold_request_duration_seconds Latency of API requests.\n")
+	keys := make([]string, 0, len(m.latencies))
+	for k := range m.latencies {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		h := m.latencies[k]
+		for i, le := range m.buckets {
+			fmt.Fprintf(&b, "uphold_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", k, le, h.counts[i])
+		}
+		fmt.Fprintf(&b, "uphold_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", k, h.count)
+		fmt.Fprintf(&b, "uphold_request_duration_seconds_sum{%s} %g\n", k, h.sum)
+		fmt.Fprintf(&b, "uphold_request_duration_seconds_count{%s} %d\n", k, h.count)
+	}
+
+	keys = keys[:0]
+	for k := range m.rates {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b.WriteString("# TYPE uphold_rate_limit_remaining gauge\n")
+	b.WriteString("# HELP uphold_rate_limit_remaining Requests remaining in the rate limit window.\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "uphold_rate_limit_remaining{%s} %d\n", k, m.rates[k].Remaining)
+	}
+	b.WriteString("# TYPE uphold_rate_limit gauge\n")
+	b.WriteString("# HELP uphold_rate_limit Requests allowed in the rate limit window.\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "uphold_rate_limit{%s} %d\n", k, m.rates[k].Limit)
+	}
+	b.WriteString("# EOF\n")
+
+	return b.WriteTo(w)
+}
+
+// ServeHTTP exposes the metrics in the OpenMetrics text format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// labels formats label pairs in the OpenMetrics text format
+func labels(kv ...string) string {
+	pairs := make([]string, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(kv[i+1])
+		pairs = append(pairs, kv[i]+`="`+v+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// sortedKeys returns the keys of m in lexical order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,106 @@
+package uphold
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/v0/me/cards", "me/cards"},
+		{"/me/cards/bc0c4d4c-64f8-4df2-a8f6-cd9d9bb4a91b/transactions", "me/cards/{id}/transactions"},
+		{"/v0/me/cards/123/transactions/456/commit", "me/cards/{id}/transactions/{id}/commit"},
+		{"/v0/ticker/USD", "ticker/{currency}"},
+		{"/v0/me/tokens/abc", "me/tokens/{id}"},
+		{"/v0/me/accounts/bc0c4d4c-64f8-4df2-a8f6-cd9d9bb4a91b", "me/accounts/{id}"},
+		{"/v0/me/contacts", "me/contacts"},
+		{"/v0/me/transactions", "me/transactions"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeEndpoint(tt.path); got != tt.want {
+			t.Errorf("NormalizeEndpoint(%q) returned %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	resp := &Response{Response: &http.Response{StatusCode: 200}}
+	tests := []struct {
+		resp *Response
+		err  error
+		want ErrorClass
+	}{
+		{resp, nil, ErrorClassNone},
+		{nil, ErrorResponse{Response: &http.Response{StatusCode: 404}}, ErrorClassClient},
+		{nil, ErrorResponse{Response: &http.Response{StatusCode: 503}}, ErrorClassServer},
+		{nil, RateLimitError{}, ErrorClassRateLimited},
+		{resp, testError{}, ErrorClassDecode},
+		{nil, testError{}, ErrorClassNetwork},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyError(tt.resp, tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v, %v) returned %q, want %q", tt.resp, tt.err, got, tt.want)
+		}
+	}
+}
+
+// testError is an error other than the API errors
+type testError struct{}
+
+func (testError) Error() string { return "test error" }
+
+func TestMetricsMiddleware(t *testing.T) {
+	setup()
+	defer teardown()
+
+	failed := false
+	mux.HandleFunc("/me/cards/123", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateLimit, "300")
+		w.Header().Set(headerRateRemaining, "298")
+		if !failed {
+			failed = true
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id":"123"}`))
+	})
+
+	m := NewMetrics()
+	client.Use(RetryMiddleware(2, time.Millisecond), MetricsMiddleware(m))
+
+	if _, _, err := client.Card.List("123"); err != nil {
+		t.Fatalf("Card.List() returned unexpected error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("Content-Type is %q, want OpenMetrics", ct)
+	}
+
+	body := rec.Body.String()
+	for _, want := range []string{
+		`uphold_requests_total{endpoint="me/cards/{id}",method="GET",class="none"} 1`,
+		`uphold_requests_total{endpoint="me/cards/{id}",method="GET",class="server_error"} 1`,
+		`uphold_retries_total{endpoint="me/cards/{id}",method="GET"} 1`,
+		`uphold_request_duration_seconds_count{endpoint="me/cards/{id}",method="GET"} 2`,
+		`uphold_rate_limit_remaining{endpoint="me/cards/{id}"} 298`,
+		`uphold_rate_limit{endpoint="me/cards/{id}"} 300`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Error("metrics should end with # EOF")
+	}
+}
@@ -0,0 +1,89 @@
+package uphold
+
+import (
+	"context"
+	"net/http"
+)
+
+// headerRequestID identifies a request across services
+const headerRequestID = "X-Request-Id"
+
+// Doer sends an API request and decodes the response into v, as Client.Do
+type Doer interface {
+	Do(req *http.Request, v interface{}) (*Response, error)
+}
+
+// DoerFunc is an adapter to use ordinary functions as Doer
+type DoerFunc func(req *http.Request, v interface{}) (*Response, error)
+
+// Do calls f(req, v)
+func (f DoerFunc) Do(req *http.Request, v interface{}) (*Response, error) {
+	return f(req, v)
+}
+
+// Middleware wraps a Doer to act on requests before they are sent and on
+// the responses after they are decoded. The returned error is the decoded
+// API error such as ErrorResponse or RateLimitError, and the Response
+// carries the RequestRate. The Response is nil if the request failed
+// before reaching the API.
+type Middleware func(next Doer) Doer
+
+// Use adds middleware to every request made by the client and its
+// services. Middleware runs in the order it is added, the first one
+// seeing the request first and the response last.
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware[:len(c.middleware):len(c.middleware)], mw...)
+}
+
+// HeaderMiddleware sets the headers h on every request,
+// replacing the values set by the client
+func HeaderMiddleware(h http.Header) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			for k, vv := range h {
+				req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), vv...)
+			}
+			return next.Do(req, v)
+		})
+	}
+}
+
+// requestIDKey is the context key of the request ID
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID,
+// to be sent by RequestIDMiddleware with the requests made in ctx
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware sends an X-Request-Id header with every request.
+// The ID is taken from the request context, see ContextWithRequestID and
+// Client.WithContext, or a new random one is generated. The ID is added to
+// the context of the request so that following middleware can read it.
+func RequestIDMiddleware() Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			id := req.Header.Get(headerRequestID)
+			if id == "" {
+				id = RequestIDFromContext(req.Context())
+			}
+			if id == "" {
+				var err error
+				if id, err = randomString(16); err != nil {
+					return nil, err
+				}
+			}
+
+			req = req.WithContext(ContextWithRequestID(req.Context(), id))
+			req.Header.Set(headerRequestID, id)
+			return next.Do(req, v)
+		})
+	}
+}
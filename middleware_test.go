@@ -0,0 +1,130 @@
+package uphold
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestUseOrder(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateLimit, "300")
+		w.Write([]byte(`[]`))
+	})
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Doer) Doer {
+			return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+				calls = append(calls, name+" "+RequestOperation(req).String())
+				resp, err := next.Do(req, v)
+				if resp == nil || resp.Limit != 300 {
+					t.Errorf("middleware %s got response %v, want rate limit 300", name, resp)
+				}
+				calls = append(calls, name+" done")
+				return resp, err
+			})
+		}
+	}
+	client.Use(trace("first"), trace("second"))
+
+	if _, _, err := client.Card.ListAll(); err != nil {
+		t.Fatalf("Card.ListAll() returned unexpected error: %v", err)
+	}
+
+	want := []string{"first Card.ListAll", "second Card.ListAll", "second done", "first done"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("middleware calls are %v, want %v", calls, want)
+	}
+}
+
+func TestMiddlewareError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	var got error
+	client.Use(func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			resp, err := next.Do(req, v)
+			got = err
+			return resp, err
+		})
+	})
+
+	client.Card.ListAll()
+	if _, ok := got.(ErrorResponse); !ok {
+		t.Errorf("middleware got error %#v, want ErrorResponse", got)
+	}
+}
+
+func TestHeaderMiddleware(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/ticker", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "X-Custom", "value")
+		testHeader(t, r, "User-Agent", "custom-agent")
+		w.Write([]byte(`[]`))
+	})
+
+	client.Use(HeaderMiddleware(http.Header{
+		"x-custom":   {"value"},
+		"User-Agent": {"custom-agent"},
+	}))
+
+	if _, _, err := client.Ticker.ListAll(); err != nil {
+		t.Errorf("Ticker.ListAll() returned unexpected error: %v", err)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var ids []string
+	mux.HandleFunc("/ticker", func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(headerRequestID))
+		w.Write([]byte(`[]`))
+	})
+
+	client.Use(RequestIDMiddleware())
+
+	client.Ticker.ListAll()
+	client.WithContext(ContextWithRequestID(context.Background(), "abc")).Ticker.ListAll()
+
+	if len(ids) != 2 || ids[0] == "" || ids[1] != "abc" {
+		t.Errorf("request IDs are %q, want a generated ID and %q", ids, "abc")
+	}
+}
+
+func TestWithContextSharesRate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/ticker", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateRemaining, "42")
+		w.Write([]byte(`[]`))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := client.WithContext(ctx)
+	if _, _, err := c.Ticker.ListAll(); err != nil {
+		t.Fatalf("Ticker.ListAll() returned unexpected error: %v", err)
+	}
+	if got := client.Rate().Remaining; got != 42 {
+		t.Errorf("Rate().Remaining is %d, want 42", got)
+	}
+
+	cancel()
+	if _, _, err := c.Ticker.ListAll(); err == nil {
+		t.Error("Ticker.ListAll() with canceled context should return an error")
+	}
+}
@@ -0,0 +1,292 @@
+package uphold
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultSessionTTL is how long an authorization may
+// take before its session expires
+const DefaultSessionTTL = 10 * time.Minute
+
+// stateCookie binds the authorization state to the browser
+// which started it, to protect against login CSRF
+const stateCookie = "uphold_oauth_state"
+
+// ErrInvalidState is returned when the state received on the callback
+// does not belong to an authorization in progress, or has expired
+var ErrInvalidState = errors.New("uphold: invalid or expired oauth state")
+
+// AuthorizationError is returned when the user or the authorization
+// server denied the authorization
+type AuthorizationError struct {
+	Code        string
+	Description string
+}
+
+// Error returns the string representation of the error
+func (e AuthorizationError) Error() string {
+	if e.Description == "" {
+		return "uphold: authorization failed: " + e.Code
+	}
+	return fmt.Sprintf("uphold: authorization failed: %s: %s", e.Code, e.Description)
+}
+
+// Session is an authorization in progress
+type Session struct {
+	State     string
+	Verifier  string
+	ExpiresOn time.Time
+}
+
+// SessionStore keeps the sessions between the start
+// and the callback steps of the authorization
+type SessionStore interface {
+	// Put stores a new session
+	Put(s Session) error
+
+	// Take removes and returns the session for given state
+	// or returns ErrInvalidState if there is none
+	Take(state string) (Session, error)
+}
+
+// MemorySessionStore keeps the sessions in memory,
+// discarding them once they expire
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+// NewMemorySessionStore returns an empty memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]Session{}}
+}
+
+// Put stores a new session
+func (m *MemorySessionStore) Put(s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for state, e := range m.sessions {
+		if now.After(e.ExpiresOn) {
+			delete(m.sessions, state)
+		}
+	}
+
+	m.sessions[s.State] = s
+	return nil
+}
+
+// Take removes and returns the session for given state
+func (m *MemorySessionStore) Take(state string) (Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[state]
+	if !ok {
+		return Session{}, ErrInvalidState
+	}
+
+	delete(m.sessions, state)
+	if time.Now().After(s.ExpiresOn) {
+		return Session{}, ErrInvalidState
+	}
+	return s, nil
+}
+
+// OAuthFlow drives the authorization code flow for users of the
+// application: it generates and verifies the state, optionally uses
+// PKCE, exchanges the code and returns a client for the user.
+type OAuthFlow struct {
+	Config   *oauth2.Config
+	Sessions SessionStore
+
+	// TTL limits how long an authorization may take,
+	// DefaultSessionTTL is used if zero
+	TTL time.Duration
+
+	// PKCE adds a S256 code challenge to the authorization
+	// request and the verifier to the code exchange
+	PKCE bool
+
+	// Sandbox makes the returned clients use the sandbox environment
+	Sandbox bool
+
+	// OnSuccess is called by the callback handler with the
+	// client and token of the user who authorized the application
+	OnSuccess func(w http.ResponseWriter, r *http.Request, c *Client, t *oauth2.Token)
+
+	// OnError is called by the handlers when the authorization fails.
+	// A plain error response is written if nil.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewOAuthFlow returns an authorization flow for the configuration
+// created by ConfigureOAuth, keeping the sessions in memory
+func NewOAuthFlow(c Credential, t Terminals, s []Permission) *OAuthFlow {
+	return &OAuthFlow{
+		Config:   ConfigureOAuth(c, t, s),
+		Sessions: NewMemorySessionStore(),
+	}
+}
+
+// AuthCodeURL starts a new authorization and returns the URL to redirect
+// the user to, along with the generated state
+func (f *OAuthFlow) AuthCodeURL() (string, string, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	ttl := f.TTL
+	if ttl == 0 {
+		ttl = DefaultSessionTTL
+	}
+	s := Session{State: state, ExpiresOn: time.Now().Add(ttl)}
+
+	opts := []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+	if f.PKCE {
+		if s.Verifier, err = randomString(32); err != nil {
+			return "", "", err
+		}
+
+		sum := sha256.Sum256([]byte(s.Verifier))
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(sum[:])),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+	}
+
+	if err := f.Sessions.Put(s); err != nil {
+		return "", "", err
+	}
+	return f.Config.AuthCodeURL(state, opts...), state, nil
+}
+
+// Exchange verifies the state of the authorization and exchanges the
+// code for a token, returning a client authenticated as the user
+func (f *OAuthFlow) Exchange(state, code string) (*Client, *oauth2.Token, error) {
+	s, err := f.Sessions.Take(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f.exchange(s, code)
+}
+
+// exchange exchanges the code of the authorization started by session s
+func (f *OAuthFlow) exchange(s Session, code string) (*Client, *oauth2.Token, error) {
+	v := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {f.Config.RedirectURL},
+	}
+	if s.Verifier != "" {
+		v.Set("code_verifier", s.Verifier)
+	}
+
+	cred := Credential{ClientID: f.Config.ClientID, ClientSecret: f.Config.ClientSecret}
+	tok, err := retrieveToken(defaultHTTPClient, cred, f.Config.Endpoint.TokenURL, v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := NewClient(f.Config.Client(oauth2.NoContext, tok))
+	if f.Sandbox {
+		c.UseSandbox()
+	}
+	c.SetGrantedScopes(ParseScopes(tok))
+	return c, tok, nil
+}
+
+// StartHandler returns a handler which starts an authorization
+// and redirects the user to the authorization server
+func (f *OAuthFlow) StartHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, state, err := f.AuthCodeURL()
+		if err != nil {
+			f.fail(w, r, err)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     stateCookie,
+			Value:    state,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+		})
+		http.Redirect(w, r, u, http.StatusFound)
+	})
+}
+
+// CallbackHandler returns a handler for the redirect URL which
+// completes the authorization and calls OnSuccess. The state is
+// verified and its session deleted on every callback, including
+// the ones reporting a failed authorization.
+func (f *OAuthFlow) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := r.FormValue("state")
+		if c, err := r.Cookie(stateCookie); err != nil || c.Value != state {
+			f.fail(w, r, ErrInvalidState)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1})
+
+		s, err := f.Sessions.Take(state)
+		if err != nil {
+			f.fail(w, r, err)
+			return
+		}
+
+		if code := r.FormValue("error"); code != "" {
+			f.fail(w, r, AuthorizationError{Code: code, Description: r.FormValue("error_description")})
+			return
+		}
+
+		c, tok, err := f.exchange(s, r.FormValue("code"))
+		if err != nil {
+			f.fail(w, r, err)
+			return
+		}
+
+		if f.OnSuccess != nil {
+			f.OnSuccess(w, r, c, tok)
+		}
+	})
+}
+
+// fail reports an authorization failure
+func (f *OAuthFlow) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if f.OnError != nil {
+		f.OnError(w, r, err)
+		return
+	}
+
+	status := http.StatusInternalServerError
+	if _, ok := err.(AuthorizationError); ok {
+		status = http.StatusForbidden
+	} else if err == ErrInvalidState {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// randomString returns n cryptographically random
+// bytes encoded with URL safe base64
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
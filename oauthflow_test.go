@@ -0,0 +1,169 @@
+package uphold
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func newTestFlow() *OAuthFlow {
+	f := NewOAuthFlow(Credential{ClientID: "id", ClientSecret: "secret"}, Terminals{
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  server.URL + "/authorize",
+			TokenURL: server.URL + "/oauth2/token",
+		},
+		RedirectURL: "http://app.example.com/callback",
+	}, []Permission{PermissionCardsRead})
+	return f
+}
+
+func TestOAuthFlow(t *testing.T) {
+	setup()
+	defer teardown()
+
+	f := newTestFlow()
+	f.PKCE = true
+
+	var challenge string
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if got, want := r.FormValue("code"), "the-code"; got != want {
+			t.Errorf("code is %v, want %v", got, want)
+		}
+
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if got := base64.RawURLEncoding.EncodeToString(sum[:]); got != challenge {
+			t.Errorf("code_verifier does not match the challenge %v", challenge)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "user-token", "refresh_token": "refresh", "expires_in": 3600}`)
+	})
+
+	rec := httptest.NewRecorder()
+	f.StartHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/login", nil))
+
+	if got, want := rec.Code, http.StatusFound; got != want {
+		t.Fatalf("start handler returned status %v, want %v", got, want)
+	}
+	loc, _ := url.Parse(rec.Header().Get("Location"))
+	state := loc.Query().Get("state")
+	challenge = loc.Query().Get("code_challenge")
+	if state == "" || challenge == "" {
+		t.Fatalf("authorization URL is missing state or challenge: %v", loc)
+	}
+	if got, want := loc.Query().Get("code_challenge_method"), "S256"; got != want {
+		t.Errorf("code_challenge_method is %v, want %v", got, want)
+	}
+
+	var token *oauth2.Token
+	f.OnSuccess = func(w http.ResponseWriter, r *http.Request, c *Client, tok *oauth2.Token) {
+		token = tok
+	}
+
+	req := httptest.NewRequest("GET", "/callback?code=the-code&state="+state, nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	f.CallbackHandler().ServeHTTP(httptest.NewRecorder(), req)
+
+	if token == nil {
+		t.Fatal("OnSuccess was not called")
+	}
+	if got, want := token.AccessToken, "user-token"; got != want {
+		t.Errorf("token is %v, want %v", got, want)
+	}
+
+	if _, _, err := f.Exchange(state, "the-code"); err != ErrInvalidState {
+		t.Errorf("reusing the state returned %v, want ErrInvalidState", err)
+	}
+}
+
+func TestOAuthFlowCallbackErrors(t *testing.T) {
+	setup()
+	defer teardown()
+
+	f := newTestFlow()
+	_, state, err := f.AuthCodeURL()
+	if err != nil {
+		t.Fatalf("AuthCodeURL() returned unexpected error: %v", err)
+	}
+
+	// the state cookie is missing
+	rec := httptest.NewRecorder()
+	f.CallbackHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/callback?code=c&state="+state, nil))
+	if got, want := rec.Code, http.StatusBadRequest; got != want {
+		t.Errorf("callback without cookie returned status %v, want %v", got, want)
+	}
+
+	// an error callback with someone else's state
+	var failure error
+	f.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+		failure = err
+	}
+	req := httptest.NewRequest("GET", "/callback?error=access_denied&state="+state, nil)
+	f.CallbackHandler().ServeHTTP(httptest.NewRecorder(), req)
+	if failure != ErrInvalidState {
+		t.Errorf("error callback without cookie reported %v, want ErrInvalidState", failure)
+	}
+
+	req = httptest.NewRequest("GET", "/callback?error=access_denied&state="+state, nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie, Value: state})
+	f.CallbackHandler().ServeHTTP(httptest.NewRecorder(), req)
+
+	want := AuthorizationError{Code: "access_denied"}
+	if failure != want {
+		t.Errorf("callback reported %v, want %v", failure, want)
+	}
+	if _, err := f.Sessions.Take(state); err != ErrInvalidState {
+		t.Errorf("session of a failed authorization was kept, Take() returned %v", err)
+	}
+}
+
+func TestOAuthFlowLiteral(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != "id" || secret != "secret" {
+			t.Errorf("token request basic auth is %v:%v, want id:secret", id, secret)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "user-token"}`)
+	})
+
+	f := &OAuthFlow{
+		Config: &oauth2.Config{
+			ClientID:     "id",
+			ClientSecret: "secret",
+			Endpoint:     oauth2.Endpoint{TokenURL: server.URL + "/oauth2/token"},
+		},
+		Sessions: NewMemorySessionStore(),
+	}
+	_, state, err := f.AuthCodeURL()
+	if err != nil {
+		t.Fatalf("AuthCodeURL() returned unexpected error: %v", err)
+	}
+	if _, tok, err := f.Exchange(state, "c"); err != nil || tok.AccessToken != "user-token" {
+		t.Errorf("Exchange() returned %v, %v", tok, err)
+	}
+}
+
+func TestMemorySessionStoreExpiry(t *testing.T) {
+	s := NewMemorySessionStore()
+	s.Put(Session{State: "old", ExpiresOn: time.Now().Add(-time.Second)})
+
+	if _, err := s.Take("old"); err != ErrInvalidState {
+		t.Errorf("Take() on expired session returned %v, want ErrInvalidState", err)
+	}
+	if _, err := s.Take("unknown"); err != ErrInvalidState {
+		t.Errorf("Take() on unknown session returned %v, want ErrInvalidState", err)
+	}
+}
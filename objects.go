@@ -1,6 +1,9 @@
 package uphold
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // TxnType is the type of transaction
 type TxnType string
@@ -21,6 +24,7 @@ const (
 	TxnStatusWaiting             = "waiting"
 	TxnStatusCancelled           = "cancelled"
 	TxnStatusCompleted           = "completed"
+	TxnStatusFailed              = "failed"
 )
 
 // FeesType is the type of fees on transaction
@@ -102,6 +106,9 @@ type Card struct {
 	Settings          *CardSettings     `json:"settings,omitempty"`
 	Addresses         *[]CardAddress    `json:"addresses,omitempty"`
 	Normalized        []NormalizedCard  `json:"normalized,omitempty"`
+
+	// Simulated is set on cards returned by a client in dry-run mode
+	Simulated bool `json:"-"`
 }
 
 // CardSettings available on card
@@ -132,6 +139,9 @@ type Contact struct {
 	Emails    []string `json:"emails,omitempty"`
 	Addresses []string `json:"addresses,omitempty"`
 	Company   string   `json:"company,omitempty"`
+
+	// Simulated is set on contacts returned by a client in dry-run mode
+	Simulated bool `json:"-"`
 }
 
 // CurrencyPair object in Uphold
@@ -155,6 +165,9 @@ type Txn struct {
 	Normalized   []Normalized  `json:"normalized,omitempty"`
 	Origin       Origin        `json:"origin,omitempty"`
 	Destination  Destination   `json:"destination,omitempty"`
+
+	// Simulated is set on transactions returned by a client in dry-run mode
+	Simulated bool `json:"-"`
 }
 
 // Quote denotes the request to perform a transaction.
@@ -164,6 +177,7 @@ type Quote struct {
 	Denomination *QuoteDenomination `json:"denomination"`
 	Origin       string             `json:"origin,omitempty"`
 	Destination  string             `json:"destination,omitempty"`
+	Network      Network            `json:"network,omitempty"`
 	Realtime     bool               `json:"-"`
 }
 
@@ -171,6 +185,22 @@ type Quote struct {
 type QuoteDenomination struct {
 	Amount   float32      `json:"amount,string"`
 	Currency CurrencyCode `json:"currency"`
+
+	// exact, if set, is sent instead of Amount, for amounts
+	// which must not lose any digit such as a whole balance
+	exact string
+}
+
+// MarshalJSON encodes the denomination, with its exact amount if set
+func (d QuoteDenomination) MarshalJSON() ([]byte, error) {
+	type quoteDenomination QuoteDenomination
+	if d.exact == "" {
+		return json.Marshal(quoteDenomination(d))
+	}
+	return json.Marshal(struct {
+		Amount   string       `json:"amount"`
+		Currency CurrencyCode `json:"currency"`
+	}{d.exact, d.Currency})
 }
 
 // Denomination is the denomination object in Uphold
@@ -248,3 +278,10 @@ type Phone struct {
 	NationalMasked      string `json:"nationalMasked,omitempty"`
 	InternationalMasked string `json:"internationalMasked,omitempty"`
 }
+
+// PersonalToken is a personal access token in Uphold
+type PersonalToken struct {
+	AccessToken string `json:"accessToken,omitempty"`
+	Description string `json:"description,omitempty"`
+	ExpiresIn   int    `json:"expiresIn,omitempty"`
+}
@@ -0,0 +1,228 @@
+package uphold
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Operation identifies a method of the services, in the form
+// Service.Method such as "Card.Add"
+type Operation string
+
+// String implements Stringer interface
+func (o Operation) String() string {
+	return string(o)
+}
+
+// Operations of the services
+const (
+	OperationTickerListAll          Operation = "Ticker.ListAll"
+	OperationTickerList             Operation = "Ticker.List"
+	OperationAccountListAll         Operation = "Account.ListAll"
+	OperationAccountList            Operation = "Account.List"
+	OperationCardListAll            Operation = "Card.ListAll"
+	OperationCardList               Operation = "Card.List"
+	OperationCardAdd                Operation = "Card.Add"
+	OperationCardUpdate             Operation = "Card.Update"
+	OperationCardDelete             Operation = "Card.Delete"
+	OperationContactListAll         Operation = "Contact.ListAll"
+	OperationContactList            Operation = "Contact.List"
+	OperationContactAdd             Operation = "Contact.Add"
+	OperationTransactionCreate      Operation = "Transaction.Create"
+	OperationTransactionCommit      Operation = "Transaction.Commit"
+	OperationTransactionCancel      Operation = "Transaction.Cancel"
+	OperationTransactionResend      Operation = "Transaction.Resend"
+	OperationTransactionListForUser Operation = "Transaction.ListForUser"
+	OperationTransactionListForCard Operation = "Transaction.ListForCard"
+	OperationPersonalTokenCreate    Operation = "PersonalToken.Create"
+	OperationPersonalTokenListAll   Operation = "PersonalToken.ListAll"
+	OperationPersonalTokenRevoke    Operation = "PersonalToken.Revoke"
+)
+
+// The permissions needed to create and commit a transaction depend
+// on its destination. These operations describe each kind of transfer
+// so ScopesFor can list the minimal permissions for them.
+const (
+	OperationTransferSelf   Operation = "Transaction.TransferSelf"
+	OperationTransferOthers Operation = "Transaction.TransferOthers"
+	OperationWithdraw       Operation = "Transaction.Withdraw"
+	OperationDeposit        Operation = "Transaction.Deposit"
+
+	// OperationTransferApplication is a transfer from the
+	// account of the application owner, see SetApplicationCredentials
+	OperationTransferApplication Operation = "Transaction.TransferApplication"
+)
+
+// operationScopes are the permissions required by each operation.
+// Operations which are not listed need no permission.
+var operationScopes = map[Operation][]Permission{
+	OperationAccountListAll:         {PermissionAccountsRead},
+	OperationAccountList:            {PermissionAccountsRead},
+	OperationCardListAll:            {PermissionCardsRead},
+	OperationCardList:               {PermissionCardsRead},
+	OperationCardAdd:                {PermissionCardsWrite},
+	OperationCardUpdate:             {PermissionCardsWrite},
+	OperationCardDelete:             {PermissionCardsWrite},
+	OperationContactListAll:         {PermissionContactsRead},
+	OperationContactList:            {PermissionContactsRead},
+	OperationContactAdd:             {PermissionContactsWrite},
+	OperationTransactionListForUser: {PermissionTransactionsRead},
+	OperationTransactionListForCard: {PermissionTransactionsRead},
+	OperationTransactionCancel:      {PermissionTransactionsTransferOthers},
+	OperationTransactionResend:      {PermissionTransactionsTransferOthers},
+	OperationTransferSelf:           {PermissionTransactionsTransferSelf},
+	OperationTransferOthers:         {PermissionTransactionsTransferOthers},
+	OperationWithdraw:               {PermissionTransactionsWithdraw},
+	OperationDeposit:                {PermissionTransactionsDeposit},
+	OperationTransferApplication:    {PermissionTransactionsTransferApplication},
+}
+
+// operationAnyScopes are the operations requiring any one of the listed
+// permissions, for transfers whose kind is only known to the API. The
+// API refuses them if the permission of their actual kind is missing.
+var operationAnyScopes = map[Operation][]Permission{
+	// A card may belong to the user or to someone else
+	operationTransferCard: {
+		PermissionTransactionsTransferSelf,
+		PermissionTransactionsTransferOthers,
+	},
+
+	// Without knowing the destination any kind of transfer is possible
+	OperationTransactionCreate: {
+		PermissionTransactionsTransferSelf,
+		PermissionTransactionsTransferOthers,
+		PermissionTransactionsWithdraw,
+	},
+	OperationTransactionCommit: {
+		PermissionTransactionsTransferSelf,
+		PermissionTransactionsTransferOthers,
+		PermissionTransactionsWithdraw,
+	},
+}
+
+// requireOperationScopes checks that granted holds the permissions
+// required by op, or one of them for the operations of operationAnyScopes
+func requireOperationScopes(granted []Permission, op Operation) error {
+	alternatives, ok := operationAnyScopes[op]
+	if !ok {
+		return RequireScopes(granted, operationScopes[op]...)
+	}
+
+	for _, p := range alternatives {
+		if RequireScopes(granted, p) == nil {
+			return nil
+		}
+	}
+	return MissingScopeError{Missing: alternatives}
+}
+
+// ScopesFor returns the permissions required to perform all
+// the given operations, suitable for ConfigureOAuth. All the
+// permissions a transfer of unknown kind may need are included.
+func ScopesFor(ops ...Operation) []Permission {
+	seen := map[Permission]bool{}
+	scopes := []Permission{}
+
+	for _, op := range ops {
+		for _, p := range append(operationScopes[op], operationAnyScopes[op]...) {
+			if !seen[p] {
+				seen[p] = true
+				scopes = append(scopes, p)
+			}
+		}
+	}
+	return scopes
+}
+
+// cardIDPattern matches the identifiers of cards
+var cardIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// operationTransferCard is a transfer to a card identifier, which
+// requires the permission of transfers to the user or to others
+// since the owner of the card is not known before the quote
+const operationTransferCard Operation = "Transaction.TransferCard"
+
+// transferOperation classifies a transfer by its destination. Card
+// identifiers may belong to anyone, emails and usernames are other
+// users and anything else is assumed to be a crypto address.
+func transferOperation(destination string) Operation {
+	switch {
+	case cardIDPattern.MatchString(destination):
+		return operationTransferCard
+	case strings.Contains(destination, "@") || strings.HasPrefix(destination, "$"):
+		return OperationTransferOthers
+	default:
+		return OperationWithdraw
+	}
+}
+
+// txnOperation classifies an existing transaction by its origin and
+// destination, bank accounts being identified by their account type.
+// Cards may belong to anyone, as in transferOperation. Transactions
+// without them, such as a bare Txn{ID}, may be any kind of transfer
+// and require the permission of one of them.
+func txnOperation(txn Txn) Operation {
+	switch txn.Origin.Type {
+	case AccountTypeSepa, AccountACH:
+		return OperationDeposit
+	}
+
+	switch txn.Destination.Type {
+	case AccountTypeSepa, AccountACH:
+		return OperationWithdraw
+	case DestinationTypeCard:
+		return operationTransferCard
+	case DestinationTypeEmail:
+		return OperationTransferOthers
+	case DestinationTypeExternal:
+		return OperationWithdraw
+	}
+	return OperationTransactionCommit
+}
+
+// operationKey is the context key of the operation of a request
+type operationKey struct{}
+
+// RequestOperation returns the operation which created the request,
+// or an empty operation if it was not created by a service
+func RequestOperation(req *http.Request) Operation {
+	op, _ := req.Context().Value(operationKey{}).(Operation)
+	return op
+}
+
+// newRequest checks that the token was granted the permissions required
+// by op before creating the request with NewRequest. The permissions of
+// scopeOp are checked instead when it is not empty. The operation is
+// attached to the request for the middleware. Application operations are
+// checked against the scopes of the application credentials and sent with
+// the application token.
+func (c *Client) newRequest(op, scopeOp Operation, method, urlStr string, body interface{}) (*http.Request, error) {
+	if scopeOp == "" {
+		scopeOp = op
+	}
+
+	app := c.isApplicationOperation(scopeOp)
+	if app && len(c.application.scopes) > 0 {
+		if err := requireOperationScopes(c.application.scopes, scopeOp); err != nil {
+			return nil, err
+		}
+	} else if !app && c.scopes != nil {
+		if err := requireOperationScopes(c.scopes, scopeOp); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := c.NewRequest(method, urlStr, body)
+	if err != nil {
+		return nil, err
+	}
+	if app {
+		if req, err = c.authorizeApplication(req); err != nil {
+			return nil, err
+		}
+	}
+	req = req.WithContext(context.WithValue(req.Context(), operationKey{}, op))
+	return c.markSimulated(op, req), nil
+}
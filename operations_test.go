@@ -0,0 +1,123 @@
+package uphold
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestScopesFor(t *testing.T) {
+	got := ScopesFor(OperationCardListAll, OperationCardAdd, OperationCardList, OperationTickerList, OperationTransferOthers)
+	want := []Permission{PermissionCardsRead, PermissionCardsWrite, PermissionTransactionsTransferOthers}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScopesFor() returned %v, want %v", got, want)
+	}
+
+	if got := ScopesFor(OperationTickerListAll); len(got) != 0 {
+		t.Errorf("ScopesFor() for public operation returned %v, want none", got)
+	}
+}
+
+func TestTransferOperation(t *testing.T) {
+	tests := map[string]Operation{
+		"bc0c4d4c-64f8-4df2-a8f6-cd9d9bb4a91b": operationTransferCard,
+		"foo@bar.com":                          OperationTransferOthers,
+		"$foobar":                              OperationTransferOthers,
+		"1GpBtJXXa1NdG94cYPGZTc3DfRY2P7EwzH":   OperationWithdraw,
+	}
+
+	for dest, want := range tests {
+		if got := transferOperation(dest); got != want {
+			t.Errorf("transferOperation(%q) returned %v, want %v", dest, got, want)
+		}
+	}
+}
+
+func TestTxnOperation(t *testing.T) {
+	tests := []struct {
+		txn  Txn
+		want Operation
+	}{
+		{Txn{ID: "t1"}, OperationTransactionCommit},
+		{Txn{Destination: Destination{Type: DestinationTypeCard}}, operationTransferCard},
+		{Txn{Destination: Destination{Type: DestinationTypeEmail}}, OperationTransferOthers},
+		{Txn{Origin: Origin{Type: AccountACH}}, OperationDeposit},
+	}
+
+	for _, tt := range tests {
+		if got := txnOperation(tt.txn); got != tt.want {
+			t.Errorf("txnOperation(%+v) returned %v, want %v", tt.txn, got, tt.want)
+		}
+	}
+}
+
+func TestCommitUnknownTransferScopes(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1/transactions/t1/commit", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"t1","status":"completed"}`))
+	})
+
+	client.SetGrantedScopes([]Permission{PermissionCardsRead})
+	_, _, err := client.Transaction.Commit(Card{ID: "c1"}, Txn{ID: "t1"}, "")
+	if _, ok := err.(MissingScopeError); !ok {
+		t.Errorf("Commit() without transfer permission returned %v, want a MissingScopeError", err)
+	}
+
+	// the API checks the permission of the actual kind of transfer
+	for _, p := range []Permission{PermissionTransactionsTransferSelf, PermissionTransactionsWithdraw} {
+		client.SetGrantedScopes([]Permission{p})
+		if _, _, err := client.Transaction.Commit(Card{ID: "c1"}, Txn{ID: "t1"}, ""); err != nil {
+			t.Errorf("Commit() of an unknown transfer with %s returned unexpected error: %v", p, err)
+		}
+	}
+}
+
+func TestPreflightMissingScope(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not be sent without the required scopes")
+	})
+
+	client.SetGrantedScopes([]Permission{PermissionCardsRead})
+
+	_, _, err := client.Card.Add(Card{Label: "label", Currency: "USD"})
+	want := MissingScopeError{Missing: []Permission{PermissionCardsWrite}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Card.Add() returned %#v, want %#v", err, want)
+	}
+}
+
+func TestPreflightTransactionDestination(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/123/transactions", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"1"}`))
+	})
+
+	client.SetGrantedScopes([]Permission{PermissionTransactionsTransferSelf})
+
+	card := Card{ID: "123"}
+	want := MissingScopeError{Missing: []Permission{PermissionTransactionsTransferOthers}}
+	if _, _, err := client.Transaction.Create(card, Quote{Destination: "foo@bar.com"}); !reflect.DeepEqual(err, want) {
+		t.Errorf("Transaction.Create() to an email returned %#v, want %#v", err, want)
+	}
+
+	// a card may be one of the user, either permission is enough
+	for _, p := range []Permission{PermissionTransactionsTransferSelf, PermissionTransactionsTransferOthers} {
+		client.SetGrantedScopes([]Permission{p})
+		if _, _, err := client.Transaction.Create(card, Quote{Destination: "bc0c4d4c-64f8-4df2-a8f6-cd9d9bb4a91b"}); err != nil {
+			t.Errorf("Transaction.Create() to a card with %s returned unexpected error: %v", p, err)
+		}
+	}
+
+	client.SetGrantedScopes([]Permission{PermissionTransactionsWithdraw})
+	want = MissingScopeError{Missing: []Permission{PermissionTransactionsTransferSelf, PermissionTransactionsTransferOthers}}
+	if _, _, err := client.Transaction.Create(card, Quote{Destination: "bc0c4d4c-64f8-4df2-a8f6-cd9d9bb4a91b"}); !reflect.DeepEqual(err, want) {
+		t.Errorf("Transaction.Create() to a card returned %#v, want %#v", err, want)
+	}
+}
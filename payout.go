@@ -0,0 +1,533 @@
+package uphold
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPayoutConcurrency is the number of transfers a Payout runs at once
+const DefaultPayoutConcurrency = 4
+
+// payoutRateLimitRetries is how many times a rate limited request is retried
+const payoutRateLimitRetries = 3
+
+// PayoutLine is a single transfer of a Payout
+type PayoutLine struct {
+	// ID identifies the line in the checkpoint, its position
+	// in the batch starting at 1 is used if empty
+	ID string `json:"id"`
+
+	// Destination is an email, a card ID or a crypto address
+	Destination string `json:"destination"`
+
+	Amount float32 `json:"amount"`
+
+	// Currency of the amount, the currency of the card if empty
+	Currency CurrencyCode `json:"currency,omitempty"`
+
+	Message string `json:"message,omitempty"`
+}
+
+// PayoutStatus is the outcome of a payout line
+type PayoutStatus string
+
+// Statuses of payout lines
+const (
+	// PayoutPending lines were not run yet
+	PayoutPending PayoutStatus = "pending"
+
+	// PayoutCommitting lines have a transaction being committed.
+	// A line found in this status when resuming has an unknown outcome.
+	PayoutCommitting PayoutStatus = "committing"
+
+	PayoutCompleted PayoutStatus = "completed"
+
+	// PayoutFailed lines were not transferred and
+	// are run again when the payout is resumed
+	PayoutFailed PayoutStatus = "failed"
+
+	// PayoutUnknown lines may or may not have been transferred. They
+	// are never run again and must be checked against the card history.
+	PayoutUnknown PayoutStatus = "unknown"
+
+	// PayoutSimulated lines were committed by a client in dry-run
+	// mode. They are never saved in the checkpoint.
+	PayoutSimulated PayoutStatus = "simulated"
+)
+
+// PayoutResult is the outcome of a payout line
+type PayoutResult struct {
+	Line          PayoutLine   `json:"line"`
+	Status        PayoutStatus `json:"status"`
+	TransactionID string       `json:"transactionId,omitempty"`
+	Error         string       `json:"error,omitempty"`
+}
+
+// PayoutReport lists the outcome of every line of a payout
+type PayoutReport struct {
+	Results   []PayoutResult
+	Completed int
+	Failed    int
+	Unknown   int
+	Pending   int
+	Simulated int
+}
+
+// WriteCSV writes one row per line of the payout
+func (r PayoutReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "destination", "amount", "currency", "status", "transaction", "error"})
+	for _, res := range r.Results {
+		cw.Write([]string{
+			res.Line.ID,
+			res.Line.Destination,
+			strconv.FormatFloat(float64(res.Line.Amount), 'f', -1, 32),
+			string(res.Line.Currency),
+			string(res.Status),
+			res.TransactionID,
+			res.Error,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CheckpointStore persists the results of the lines of a payout,
+// so that an interrupted payout can be resumed
+type CheckpointStore interface {
+	// Load returns the saved results by line ID
+	Load() (map[string]PayoutResult, error)
+
+	// Save records the result of a line
+	Save(r PayoutResult) error
+}
+
+// MemoryCheckpointStore keeps the checkpoint in memory
+type MemoryCheckpointStore struct {
+	mu      sync.Mutex
+	results map[string]PayoutResult
+}
+
+// NewMemoryCheckpointStore returns an empty memory checkpoint store
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{results: map[string]PayoutResult{}}
+}
+
+// Load returns the saved results
+func (m *MemoryCheckpointStore) Load() (map[string]PayoutResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	results := make(map[string]PayoutResult, len(m.results))
+	for k, v := range m.results {
+		results[k] = v
+	}
+	return results, nil
+}
+
+// Save records the result of a line
+func (m *MemoryCheckpointStore) Save(r PayoutResult) error {
+	m.mu.Lock()
+	m.results[r.Line.ID] = r
+	m.mu.Unlock()
+	return nil
+}
+
+// FileCheckpointStore keeps the checkpoint in a JSON file,
+// rewritten atomically on every save
+type FileCheckpointStore struct {
+	mu      sync.Mutex
+	path    string
+	results map[string]PayoutResult
+}
+
+// NewFileCheckpointStore returns a store keeping the checkpoint at path
+func NewFileCheckpointStore(path string) *FileCheckpointStore {
+	return &FileCheckpointStore{path: path}
+}
+
+// Load reads the saved results, none if the file does not exist
+func (f *FileCheckpointStore) Load() (map[string]PayoutResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]PayoutResult, len(f.results))
+	for k, v := range f.results {
+		results[k] = v
+	}
+	return results, nil
+}
+
+// load reads the file once, f.mu must be held
+func (f *FileCheckpointStore) load() error {
+	if f.results != nil {
+		return nil
+	}
+
+	f.results = map[string]PayoutResult{}
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &f.results)
+}
+
+// Save records the result of a line and rewrites the file
+func (f *FileCheckpointStore) Save(r PayoutResult) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.load(); err != nil {
+		return err
+	}
+	f.results[r.Line.ID] = r
+
+	b, err := json.MarshalIndent(f.results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, b, 0600)
+}
+
+// PayoutValidationError lists the problems found by Payout.Validate
+type PayoutValidationError struct {
+	Problems []string
+}
+
+// Error returns the string representation of the error
+func (e PayoutValidationError) Error() string {
+	msg := "uphold: invalid payout:"
+	for _, p := range e.Problems {
+		msg += "\n  " + p
+	}
+	return msg
+}
+
+// Payout transfers funds from one card to many destinations
+type Payout struct {
+	Client *Client
+	Card   Card
+	Lines  []PayoutLine
+
+	// Concurrency is the number of transfers run at once,
+	// DefaultPayoutConcurrency is used if zero
+	Concurrency int
+
+	// Interval is the minimum time between the start of two transfers
+	Interval time.Duration
+
+	// Checkpoint, if set, records the result of every line so that
+	// running the payout again resumes it
+	Checkpoint CheckpointStore
+}
+
+// NewPayout returns a payout of the lines from card
+func NewPayout(c *Client, card Card, lines []PayoutLine) *Payout {
+	return &Payout{Client: c, Card: card, Lines: lines}
+}
+
+// prepare sets the default ID and currency of the lines, once
+// the card is loaded
+func (p *Payout) prepare() {
+	for i := range p.Lines {
+		if p.Lines[i].ID == "" {
+			p.Lines[i].ID = strconv.Itoa(i + 1)
+		}
+		if p.Lines[i].Currency == "" {
+			p.Lines[i].Currency = CurrencyCode(p.Card.Currency)
+		}
+	}
+}
+
+// Validate checks the lines and that the available balance of the card
+// covers the lines which remain to be paid, converting their amounts
+// with the ticker when needed. Fees are not accounted for.
+func (p *Payout) Validate() error {
+	card, _, err := p.Client.Card.List(p.Card.ID)
+	if err != nil {
+		return err
+	}
+	p.Card = *card
+	p.prepare()
+
+	done, err := p.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	seen := map[string]bool{}
+	for n, l := range p.Lines {
+		if seen[l.ID] {
+			problems = append(problems, fmt.Sprintf("line %d: duplicate ID %q", n+1, l.ID))
+		}
+		seen[l.ID] = true
+
+		if l.Destination == "" {
+			problems = append(problems, fmt.Sprintf("line %s: missing destination", l.ID))
+		}
+		if l.Amount <= 0 {
+			problems = append(problems, fmt.Sprintf("line %s: amount must be positive", l.ID))
+		}
+	}
+	if len(problems) > 0 {
+		return PayoutValidationError{Problems: problems}
+	}
+
+	var rates map[string]float32
+	var total float32
+	for _, l := range p.Lines {
+		if !runnable(done[l.ID].Status) {
+			continue
+		}
+		if string(l.Currency) == card.Currency {
+			total += l.Amount
+			continue
+		}
+
+		if rates == nil {
+			if rates, err = p.rates(); err != nil {
+				return err
+			}
+		}
+		rate, ok := rates[string(l.Currency)+card.Currency]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("line %s: no rate from %s to %s", l.ID, l.Currency, card.Currency))
+			continue
+		}
+		total += l.Amount * rate
+	}
+
+	if total > card.Available {
+		problems = append(problems, fmt.Sprintf("payout of %g %s exceeds the available balance of %g %s",
+			total, card.Currency, card.Available, card.Currency))
+	}
+	if len(problems) > 0 {
+		return PayoutValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// rates returns the ask price of the pairs quoted in the card currency
+func (p *Payout) rates() (map[string]float32, error) {
+	pairs, _, err := p.Client.Ticker.List(CurrencyCode(p.Card.Currency))
+	if err != nil {
+		return nil, err
+	}
+
+	rates := map[string]float32{}
+	for _, pair := range *pairs {
+		rates[pair.Pair] = pair.Ask
+	}
+	return rates, nil
+}
+
+// loadCheckpoint returns the saved results, none without checkpoint
+func (p *Payout) loadCheckpoint() (map[string]PayoutResult, error) {
+	if p.Checkpoint == nil {
+		return map[string]PayoutResult{}, nil
+	}
+	return p.Checkpoint.Load()
+}
+
+// runnable reports whether a line in status s must be run
+func runnable(s PayoutStatus) bool {
+	return s == "" || s == PayoutPending || s == PayoutFailed
+}
+
+// Run validates the payout and transfers the lines which remain to be
+// paid. Lines completed by a previous run, according to the checkpoint,
+// are skipped. Canceling ctx stops starting new transfers. The report
+// lists every line, and an error is only returned if the payout could
+// not be started.
+func (p *Payout) Run(ctx context.Context) (*PayoutReport, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	done, err := p.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PayoutResult, len(p.Lines))
+	var todo []int
+	for i, l := range p.Lines {
+		r, ok := done[l.ID]
+		switch {
+		case !ok:
+			r = PayoutResult{Status: PayoutPending}
+		case r.Status == PayoutCommitting:
+			r.Status = PayoutUnknown
+			r.Error = "interrupted while committing, check the card transactions"
+			p.save(r)
+		}
+		r.Line = l
+		results[i] = r
+
+		if runnable(r.Status) {
+			todo = append(todo, i)
+		}
+	}
+
+	client := p.Client.WithContext(ctx)
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultPayoutConcurrency
+	}
+
+	var tick <-chan time.Time
+	if p.Interval > 0 {
+		t := time.NewTicker(p.Interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = p.transfer(ctx, client, results[i].Line)
+			}
+		}()
+	}
+
+dispatch:
+	for n, i := range todo {
+		if tick != nil && n > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				break dispatch
+			}
+		}
+
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := &PayoutReport{Results: results}
+	for _, r := range results {
+		switch r.Status {
+		case PayoutCompleted:
+			report.Completed++
+		case PayoutFailed:
+			report.Failed++
+		case PayoutUnknown:
+			report.Unknown++
+		case PayoutSimulated:
+			report.Simulated++
+		default:
+			report.Pending++
+		}
+	}
+	return report, nil
+}
+
+// transfer creates and commits the transaction of a line
+func (p *Payout) transfer(ctx context.Context, c *Client, l PayoutLine) PayoutResult {
+	r := PayoutResult{Line: l}
+
+	q := Quote{
+		Denomination: &QuoteDenomination{Amount: l.Amount, Currency: l.Currency},
+		Destination:  l.Destination,
+	}
+
+	var txn *Txn
+	err := retryRateLimited(ctx, func() (err error) {
+		txn, _, err = c.Transaction.Create(p.Card, q)
+		return err
+	})
+	if err != nil {
+		r.Status, r.Error = PayoutFailed, err.Error()
+		p.save(r)
+		return r
+	}
+
+	r.Status, r.TransactionID = PayoutCommitting, txn.ID
+	if err := p.save(r); err != nil {
+		// without a checkpoint the line could be paid twice on resume
+		r.Status, r.Error = PayoutFailed, "cannot save checkpoint: "+err.Error()
+		return r
+	}
+
+	var committed *Txn
+	err = retryRateLimited(ctx, func() (err error) {
+		committed, _, err = c.Transaction.Commit(p.Card, *txn, l.Message)
+		return err
+	})
+	switch {
+	case err == nil && committed.Simulated:
+		r.Status, r.Error = PayoutSimulated, ""
+	case err == nil:
+		r.Status, r.Error = PayoutCompleted, ""
+		if committed.ID != "" {
+			r.TransactionID = committed.ID
+		}
+	default:
+		r.Status, r.Error = commitStatus(err), err.Error()
+	}
+
+	p.save(r)
+	return r
+}
+
+// commitStatus classifies a failed commit
+func commitStatus(err error) PayoutStatus {
+	if commitRefused(err) {
+		return PayoutFailed
+	}
+	return PayoutUnknown
+}
+
+// save records the result in the checkpoint, if any. Nothing is
+// saved in dry-run mode, where no line is actually paid.
+func (p *Payout) save(r PayoutResult) error {
+	if p.Checkpoint == nil || p.Client.DryRun() || r.Status == PayoutSimulated {
+		return nil
+	}
+	return p.Checkpoint.Save(r)
+}
+
+// retryRateLimited calls f again after the delay given by the API as
+// long as it fails with a RateLimitError, unless ctx is done meanwhile
+func retryRateLimited(ctx context.Context, f func() error) error {
+	for n := 0; ; n++ {
+		err := f()
+		e, ok := err.(RateLimitError)
+		if !ok || n >= payoutRateLimitRetries {
+			return err
+		}
+
+		t := time.NewTimer(time.Duration(e.RetryAfter) * time.Second)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+	}
+}
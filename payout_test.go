@@ -0,0 +1,230 @@
+package uphold
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// payoutServer fakes the endpoints used by payouts and
+// counts the transactions created by destination
+func payoutServer(t *testing.T) map[string]int {
+	var mu sync.Mutex
+	created := map[string]int{}
+
+	mux.HandleFunc("/me/cards/c1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"c1","currency":"USD","available":"100.00"}`)
+	})
+	mux.HandleFunc("/ticker/USD", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"pair":"EURUSD","ask":"2"}]`)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+
+		var q Quote
+		json.NewDecoder(r.Body).Decode(&q)
+		if q.Destination == "refused@example.com" {
+			http.Error(w, "invalid destination", http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		created[q.Destination]++
+		mu.Unlock()
+		fmt.Fprintf(w, `{"id":"t-%s","status":"pending"}`, q.Destination)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/me/cards/c1/transactions/"), "/commit")
+		fmt.Fprintf(w, `{"id":"%s","status":"completed"}`, id)
+	})
+	return created
+}
+
+func TestPayoutRun(t *testing.T) {
+	setup()
+	defer teardown()
+	created := payoutServer(t)
+
+	p := NewPayout(client, Card{ID: "c1"}, []PayoutLine{
+		{Destination: "foo@example.com", Amount: 10},
+		{Destination: "refused@example.com", Amount: 10},
+		{Destination: "bar@example.com", Amount: 10, Currency: "EUR"},
+	})
+	p.Concurrency = 2
+
+	report, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() returned unexpected error: %v", err)
+	}
+
+	if report.Completed != 2 || report.Failed != 1 {
+		t.Errorf("Run() completed %d and failed %d lines, want 2 and 1", report.Completed, report.Failed)
+	}
+	if r := report.Results[0]; r.Status != PayoutCompleted || r.TransactionID != "t-foo@example.com" || r.Line.ID != "1" {
+		t.Errorf("first result is %+v, want completed transaction of line 1", r)
+	}
+	if r := report.Results[1]; r.Status != PayoutFailed || r.Error == "" {
+		t.Errorf("second result is %+v, want failed", r)
+	}
+	if created["foo@example.com"] != 1 || created["bar@example.com"] != 1 {
+		t.Errorf("created transactions %v, want one per destination", created)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := report.WriteCSV(buf); err != nil {
+		t.Fatalf("WriteCSV() returned unexpected error: %v", err)
+	}
+	want := "id,destination,amount,currency,status,transaction,error\n1,foo@example.com,10,USD,completed,t-foo@example.com,\n"
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("WriteCSV() wrote %q, want prefix %q", buf.String(), want)
+	}
+}
+
+func TestPayoutValidate(t *testing.T) {
+	setup()
+	defer teardown()
+	payoutServer(t)
+
+	p := NewPayout(client, Card{ID: "c1"}, []PayoutLine{
+		{Destination: "foo@example.com", Amount: 60},
+		{Destination: "bar@example.com", Amount: 30, Currency: "EUR"},
+	})
+	err := p.Validate()
+	if e, ok := err.(PayoutValidationError); !ok || len(e.Problems) != 1 || !strings.Contains(e.Problems[0], "exceeds the available balance") {
+		t.Errorf("Validate() returned %v, want balance exceeded", err)
+	}
+
+	p.Lines = []PayoutLine{{Amount: 10}, {Destination: "foo@example.com"}}
+	if e, ok := p.Validate().(PayoutValidationError); !ok || len(e.Problems) != 2 {
+		t.Errorf("Validate() returned %v, want two problems", e)
+	}
+
+	if _, err := p.Run(context.Background()); err == nil {
+		t.Error("Run() should not start an invalid payout")
+	}
+}
+
+func TestPayoutResume(t *testing.T) {
+	setup()
+	defer teardown()
+	created := payoutServer(t)
+
+	lines := []PayoutLine{
+		{ID: "a", Destination: "done@example.com", Amount: 10},
+		{ID: "b", Destination: "interrupted@example.com", Amount: 10},
+		{ID: "c", Destination: "failed@example.com", Amount: 10},
+	}
+
+	cp := NewMemoryCheckpointStore()
+	cp.Save(PayoutResult{Line: lines[0], Status: PayoutCompleted, TransactionID: "t1"})
+	cp.Save(PayoutResult{Line: lines[1], Status: PayoutCommitting, TransactionID: "t2"})
+	cp.Save(PayoutResult{Line: lines[2], Status: PayoutFailed})
+
+	p := NewPayout(client, Card{ID: "c1"}, lines)
+	p.Checkpoint = cp
+
+	report, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() returned unexpected error: %v", err)
+	}
+
+	if len(created) != 1 || created["failed@example.com"] != 1 {
+		t.Errorf("created transactions %v, want only the failed line", created)
+	}
+	if report.Completed != 2 || report.Unknown != 1 || report.Results[1].Status != PayoutUnknown {
+		t.Errorf("report is %+v, want 2 completed and the interrupted line unknown", report)
+	}
+
+	saved, _ := cp.Load()
+	if saved["c"].Status != PayoutCompleted || saved["b"].Status != PayoutUnknown {
+		t.Errorf("checkpoint is %+v, want c completed and b unknown", saved)
+	}
+}
+
+func TestPayoutDryRun(t *testing.T) {
+	setup()
+	defer teardown()
+	payoutServer(t)
+
+	client.SetDryRun(true)
+	cp := NewMemoryCheckpointStore()
+	lines := []PayoutLine{
+		{ID: "a", Destination: "foo@example.com", Amount: 10},
+		{ID: "b", Destination: "bar@example.com", Amount: 10},
+	}
+	p := NewPayout(client, Card{ID: "c1"}, lines)
+	p.Checkpoint = cp
+
+	report, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() returned unexpected error: %v", err)
+	}
+	if report.Simulated != len(lines) || report.Pending != 0 || report.Completed != 0 || report.Results[0].Status != PayoutSimulated {
+		t.Errorf("report is %+v, want %d simulated lines", report, len(lines))
+	}
+	if saved, _ := cp.Load(); len(saved) != 0 {
+		t.Errorf("dry run saved checkpoint %+v", saved)
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uphold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "payout.json")
+	if err := NewFileCheckpointStore(path).Save(PayoutResult{Line: PayoutLine{ID: "1"}, Status: PayoutCompleted}); err != nil {
+		t.Fatalf("Save() returned unexpected error: %v", err)
+	}
+
+	results, err := NewFileCheckpointStore(path).Load()
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if results["1"].Status != PayoutCompleted {
+		t.Errorf("Load() returned %+v, want line 1 completed", results)
+	}
+}
+
+func TestCommitStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want PayoutStatus
+	}{
+		{ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadRequest}}, PayoutFailed},
+		{ErrorResponse{Response: &http.Response{StatusCode: http.StatusInternalServerError}}, PayoutUnknown},
+		{ErrorResponse{Response: &http.Response{StatusCode: http.StatusGatewayTimeout}}, PayoutUnknown},
+		{PolicyViolationError{Rule: RuleMaxAmount}, PayoutFailed},
+		{context.DeadlineExceeded, PayoutUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := commitStatus(tt.err); got != tt.want {
+			t.Errorf("commitStatus(%v) returned %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryRateLimitedCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := retryRateLimited(ctx, func() error {
+		calls++
+		return RateLimitError{ErrorResponse{&http.Response{StatusCode: http.StatusTooManyRequests}}, RequestRate{RetryAfter: 60}}
+	})
+	if _, ok := err.(RateLimitError); !ok || calls != 1 {
+		t.Errorf("retryRateLimited() returned %v after %d calls, want a RateLimitError after 1", err, calls)
+	}
+}
@@ -0,0 +1,60 @@
+package uphold
+
+import "fmt"
+
+// PersonalTokenService works with personal access token API endpoints
+type PersonalTokenService struct {
+	client *Client
+}
+
+// Create a new personal access token. The request must be authenticated
+// with SetBasicAuth, and otp is the one time password of the user if two
+// factor authentication is enabled.
+func (p *PersonalTokenService) Create(description, otp string) (*PersonalToken, *Response, error) {
+	payload := map[string]string{"description": description}
+
+	req, err := p.client.newRequest(OperationPersonalTokenCreate, "", "POST", "me/tokens", payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if otp != "" {
+		req.Header.Set(headerOTP, otp)
+	}
+
+	token := new(PersonalToken)
+	resp, err := p.client.Do(req, token)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return token, resp, nil
+}
+
+// ListAll personal access tokens of the user
+func (p *PersonalTokenService) ListAll() (*[]PersonalToken, *Response, error) {
+	req, err := p.client.newRequest(OperationPersonalTokenListAll, "", "GET", "me/tokens", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens := new([]PersonalToken)
+	resp, err := p.client.Do(req, tokens)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return tokens, resp, nil
+}
+
+// Revoke a personal access token
+func (p *PersonalTokenService) Revoke(token string) (*Response, error) {
+	rel := fmt.Sprintf("me/tokens/%s", token)
+
+	req, err := p.client.newRequest(OperationPersonalTokenRevoke, "", "DELETE", rel, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.client.Do(req, nil)
+}
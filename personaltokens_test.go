@@ -0,0 +1,74 @@
+package uphold
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestPersonalTokenCreate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.SetBasicAuth("user@example.com", "password")
+
+	mux.HandleFunc("/me/tokens", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testHeader(t, r, "OTP-Token", "123456")
+		testBody(t, r, `{"description":"tooling"}`)
+
+		if u, p, ok := r.BasicAuth(); !ok || u != "user@example.com" || p != "password" {
+			t.Errorf("Request basic auth is %v:%v, want user@example.com:password", u, p)
+		}
+
+		fmt.Fprint(w, `{"accessToken": "pat", "description": "tooling", "expiresIn": 0}`)
+	})
+
+	token, _, err := client.PersonalToken.Create("tooling", "123456")
+	if err != nil {
+		t.Fatalf("PersonalToken.Create() returned unexpected error: %v", err)
+	}
+
+	want := &PersonalToken{AccessToken: "pat", Description: "tooling"}
+	if !reflect.DeepEqual(token, want) {
+		t.Errorf("PersonalToken.Create() returned %+v, want %+v", token, want)
+	}
+}
+
+func TestPersonalTokenListAll(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.SetPersonalAccessToken("pat")
+
+	mux.HandleFunc("/me/tokens", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testHeader(t, r, "Authorization", "Bearer pat")
+		fmt.Fprint(w, `[{"description": "tooling"}, {"description": "scripts"}]`)
+	})
+
+	tokens, _, err := client.PersonalToken.ListAll()
+	if err != nil {
+		t.Fatalf("PersonalToken.ListAll() returned unexpected error: %v", err)
+	}
+
+	want := &[]PersonalToken{{Description: "tooling"}, {Description: "scripts"}}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("PersonalToken.ListAll() returned %+v, want %+v", tokens, want)
+	}
+}
+
+func TestPersonalTokenRevoke(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/tokens/pat", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	if _, err := client.PersonalToken.Revoke("pat"); err != nil {
+		t.Errorf("PersonalToken.Revoke() returned unexpected error: %v", err)
+	}
+}
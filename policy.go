@@ -0,0 +1,445 @@
+package uphold
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PolicyRule names a rule of a Policy
+type PolicyRule string
+
+// Rules of a Policy
+const (
+	RuleMaxAmount     PolicyRule = "max_amount"
+	RuleWindowLimit   PolicyRule = "window_limit"
+	RuleDestination   PolicyRule = "destination"
+	RuleBusinessHours PolicyRule = "business_hours"
+	RuleConfirmation  PolicyRule = "confirmation"
+
+	// RuleUnknownTransaction refuses to commit transactions which
+	// were not quoted by the client, and checked by its policy
+	RuleUnknownTransaction PolicyRule = "unknown_transaction"
+)
+
+// PolicyViolationError is returned by the transaction service
+// when a transaction breaks a rule of the client policy
+type PolicyViolationError struct {
+	Rule   PolicyRule
+	Reason string
+}
+
+// Error returns the string representation of the error
+func (e PolicyViolationError) Error() string {
+	return fmt.Sprintf("uphold: policy violation (%s): %s", e.Rule, e.Reason)
+}
+
+// WindowLimit caps the amount transferred in a currency
+// over a rolling window of time
+type WindowLimit struct {
+	Currency CurrencyCode
+	Amount   float32
+	Window   time.Duration
+}
+
+// BusinessHours restricts transactions to some hours of some days
+type BusinessHours struct {
+	// Location of the hours, UTC if nil
+	Location *time.Location
+
+	// Start and End hours, End excluded
+	Start, End int
+
+	// Days allowed, Monday to Friday if empty
+	Days []time.Weekday
+}
+
+// contains reports whether t is within the business hours
+func (b BusinessHours) contains(t time.Time) bool {
+	loc := b.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	days := b.Days
+	if len(days) == 0 {
+		days = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	}
+
+	for _, d := range days {
+		if d == t.Weekday() {
+			return t.Hour() >= b.Start && t.Hour() < b.End
+		}
+	}
+	return false
+}
+
+// PolicyRequest describes a transaction checked against a Policy. Once
+// the transaction is quoted, Amount and Currency are those taken from
+// the origin card according to the API.
+type PolicyRequest struct {
+	Operation   Operation
+	Card        Card
+	Amount      float32
+	Currency    CurrencyCode
+	Destination string
+
+	// Transaction is the ID of the quoted transaction,
+	// empty before the quote is created
+	Transaction string
+}
+
+// policyQuoteTTL is how long a quote checked by a policy can be
+// committed, well beyond the expiry of quotes by the API
+const policyQuoteTTL = 15 * time.Minute
+
+// Policy guards the transactions created and committed by a client.
+// Quotes are checked before they are sent, in the currency of their
+// denomination, and again once the API returned them, on the amount
+// taken from the origin card. Only transactions quoted by the client
+// can be committed, and they are checked again as quoted when they are
+// committed. With a policy, realtime quotes are created, checked and
+// then committed. Deposits from bank accounts, as told by the API, are
+// not checked.
+type Policy struct {
+	// MaxAmount caps the amount of a single transaction by currency
+	MaxAmount map[CurrencyCode]float32
+
+	// Limits cap the amount committed over rolling windows
+	Limits []WindowLimit
+
+	// AllowedDestinations, if not empty, are the only card IDs, emails
+	// or addresses transactions can be sent to, along with the contacts
+	// of the user if AllowContacts is set. An XRP address allows any of
+	// its destination tags, an address with a tag only allows that tag.
+	AllowedDestinations []string
+	AllowContacts       bool
+
+	// BusinessHours, if set, restricts when transactions can be made
+	BusinessHours *BusinessHours
+
+	// ConfirmAbove sets the amounts by currency above which Confirm
+	// must approve the transaction before it is committed
+	ConfirmAbove map[CurrencyCode]float32
+	Confirm      func(r PolicyRequest) (bool, error)
+
+	// now returns the current time, for tests
+	now func() time.Time
+
+	mu     sync.Mutex
+	spent  []spending
+	quotes map[string]policyQuote
+}
+
+// spending is an amount committed, or being committed by
+// the transaction txn, at some time
+type spending struct {
+	at       time.Time
+	amount   float32
+	currency CurrencyCode
+	txn      string
+}
+
+// policyQuote is a quote checked by the policy
+type policyQuote struct {
+	r  PolicyRequest
+	at time.Time
+}
+
+// SetPolicy guards the transactions of the client with p,
+// or removes the policy if p is nil
+func (c *Client) SetPolicy(p *Policy) {
+	c.policy = p
+}
+
+// time returns the current time
+func (p *Policy) time() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// checkQuote checks a quote about to be created. Deposits
+// are checked once the API tells where they come from.
+func (p *Policy) checkQuote(c *Client, r PolicyRequest) error {
+	if r.Operation == OperationDeposit {
+		return nil
+	}
+	if err := p.checkMaxAmount(r); err != nil {
+		return err
+	}
+	if err := p.checkDestination(c, r.Destination); err != nil {
+		return err
+	}
+	if err := p.checkHours(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.checkWindows(r)
+}
+
+// quoted checks the quote r returned by the API for the quote asked,
+// on the amount taken from the origin card, and remembers it so that it
+// can be committed. A quote asked as a deposit which the API does not
+// show coming from a bank account was not checked yet, all rules apply.
+func (p *Policy) quoted(c *Client, asked, r PolicyRequest) error {
+	if r.Operation != OperationDeposit {
+		if asked.Operation == OperationDeposit {
+			if err := p.checkDestination(c, r.Destination); err != nil {
+				return err
+			}
+			if err := p.checkHours(); err != nil {
+				return err
+			}
+		}
+		if err := p.checkMaxAmount(r); err != nil {
+			return err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r.Operation != OperationDeposit {
+		if err := p.checkWindows(r); err != nil {
+			return err
+		}
+	}
+
+	now := p.time()
+	if p.quotes == nil {
+		p.quotes = map[string]policyQuote{}
+	}
+	for id, q := range p.quotes {
+		if now.Sub(q.at) >= policyQuoteTTL {
+			delete(p.quotes, id)
+		}
+	}
+	p.quotes[r.Transaction] = policyQuote{r: r, at: now}
+	return nil
+}
+
+// checkCommit checks the transaction id about to be committed as it was
+// quoted, and reserves its amount in the rolling windows. The returned
+// function must be called with whether the transaction was committed,
+// to release the reservation of a transaction which was not.
+func (p *Policy) checkCommit(c *Client, id string) (func(committed bool), error) {
+	p.mu.Lock()
+	q, ok := p.quotes[id]
+	p.mu.Unlock()
+
+	if !ok || p.time().Sub(q.at) >= policyQuoteTTL {
+		return nil, PolicyViolationError{RuleUnknownTransaction, fmt.Sprintf("transaction %s was not quoted by this client", id)}
+	}
+
+	r := q.r
+	if r.Operation == OperationDeposit {
+		return func(committed bool) { p.settle(id, committed) }, nil
+	}
+
+	if err := p.checkMaxAmount(r); err != nil {
+		return nil, err
+	}
+	if err := p.checkDestination(c, r.Destination); err != nil {
+		return nil, err
+	}
+	if err := p.checkHours(); err != nil {
+		return nil, err
+	}
+	if err := p.checkConfirmation(r); err != nil {
+		return nil, err
+	}
+
+	if err := p.reserve(r); err != nil {
+		return nil, err
+	}
+	return func(committed bool) { p.settle(id, committed) }, nil
+}
+
+// checkMaxAmount checks the amount of a single transaction
+func (p *Policy) checkMaxAmount(r PolicyRequest) error {
+	if max, ok := p.MaxAmount[r.Currency]; ok && r.Amount > max {
+		return PolicyViolationError{RuleMaxAmount, fmt.Sprintf("%g %s exceeds the limit of %g %s per transaction", r.Amount, r.Currency, max, r.Currency)}
+	}
+	return nil
+}
+
+// checkConfirmation asks Confirm to approve large transactions
+func (p *Policy) checkConfirmation(r PolicyRequest) error {
+	threshold, ok := p.ConfirmAbove[r.Currency]
+	if !ok || r.Amount <= threshold {
+		return nil
+	}
+
+	if p.Confirm == nil {
+		return PolicyViolationError{RuleConfirmation, fmt.Sprintf("%g %s requires a confirmation", r.Amount, r.Currency)}
+	}
+	ok, err := p.Confirm(r)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return PolicyViolationError{RuleConfirmation, fmt.Sprintf("%g %s to %s was not confirmed", r.Amount, r.Currency, r.Destination)}
+	}
+	return nil
+}
+
+// checkHours checks the business hours
+func (p *Policy) checkHours() error {
+	if p.BusinessHours != nil && !p.BusinessHours.contains(p.time()) {
+		return PolicyViolationError{RuleBusinessHours, "transactions are not allowed at this time"}
+	}
+	return nil
+}
+
+// checkDestination checks the destination against the allowlist. An
+// allowed XRP address allows it with any destination tag.
+func (p *Policy) checkDestination(c *Client, dest string) error {
+	if len(p.AllowedDestinations) == 0 && !p.AllowContacts {
+		return nil
+	}
+
+	address := dest
+	if i := strings.Index(dest, destinationTagSeparator); i >= 0 {
+		address = dest[:i]
+	}
+	allowed := func(d string) bool {
+		return strings.EqualFold(d, dest) || strings.EqualFold(d, address)
+	}
+
+	for _, d := range p.AllowedDestinations {
+		if allowed(d) {
+			return nil
+		}
+	}
+
+	if p.AllowContacts {
+		contacts, _, err := c.Contact.ListAll()
+		if err != nil {
+			return err
+		}
+		for _, ct := range *contacts {
+			for _, list := range [][]string{ct.Emails, ct.Addresses} {
+				for _, d := range list {
+					if allowed(d) {
+						return nil
+					}
+				}
+			}
+		}
+	}
+
+	return PolicyViolationError{RuleDestination, fmt.Sprintf("destination %s is not allowed", dest)}
+}
+
+// checkWindows checks that the amount fits in the rolling windows
+// along with the amounts committed or being committed, p.mu must be held
+func (p *Policy) checkWindows(r PolicyRequest) error {
+	now := p.time()
+	for _, l := range p.Limits {
+		if l.Currency != r.Currency {
+			continue
+		}
+
+		total := r.Amount
+		for _, s := range p.spent {
+			if s.currency == l.Currency && now.Sub(s.at) < l.Window {
+				total += s.amount
+			}
+		}
+		if total > l.Amount {
+			return PolicyViolationError{RuleWindowLimit, fmt.Sprintf("%g %s exceeds the limit of %g %s per %s", total, l.Currency, l.Amount, l.Currency, l.Window)}
+		}
+	}
+	return nil
+}
+
+// reserve checks the rolling windows and accounts the
+// amount of the transaction being committed in them
+func (p *Policy) reserve(r PolicyRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.checkWindows(r); err != nil {
+		return err
+	}
+
+	now := p.time()
+	var longest time.Duration
+	for _, l := range p.Limits {
+		if l.Window > longest {
+			longest = l.Window
+		}
+	}
+
+	kept := p.spent[:0]
+	for _, s := range p.spent {
+		if now.Sub(s.at) < longest {
+			kept = append(kept, s)
+		}
+	}
+	p.spent = append(kept, spending{at: now, amount: r.Amount, currency: r.Currency, txn: r.Transaction})
+	return nil
+}
+
+// settle forgets the quote of a committed transaction, or
+// releases the reservation of a transaction which was not
+func (p *Policy) settle(id string, committed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if committed {
+		delete(p.quotes, id)
+		return
+	}
+
+	for i, s := range p.spent {
+		if s.txn == id {
+			p.spent = append(p.spent[:i], p.spent[i+1:]...)
+			return
+		}
+	}
+}
+
+// quoteRequest describes a quote about to be created on card
+func quoteRequest(card Card, q Quote, kind Operation) PolicyRequest {
+	r := PolicyRequest{Operation: kind, Card: card, Destination: q.Destination}
+	if q.Denomination != nil {
+		r.Amount = q.Denomination.Amount
+		r.Currency = q.Denomination.Currency
+	}
+	return r
+}
+
+// quotedRequest describes the quote txn returned by the API for r,
+// with the amount taken from the origin card when the API gives it.
+// Deposits remain deposits only if the API tells they come from a SEPA
+// or ACH bank account.
+func quotedRequest(r PolicyRequest, txn Txn) PolicyRequest {
+	r.Transaction = txn.ID
+	if r.Operation == OperationDeposit && txnOperation(txn) != OperationDeposit {
+		r.Operation, r.Destination = txnOperation(txn), txnDestination(txn)
+	}
+	if txn.Origin.Currency != "" {
+		r.Amount = txn.Origin.Amount
+		r.Currency = CurrencyCode(txn.Origin.Currency)
+	} else if d := txn.Denomination; d != nil && d.Currency != "" {
+		r.Amount = d.Amount
+		r.Currency = CurrencyCode(d.Currency)
+	}
+	return r
+}
+
+// txnDestination returns the card ID the transaction is sent to,
+// or the description of the destination for other destinations
+func txnDestination(txn Txn) string {
+	if txn.Destination.CardID != "" {
+		return txn.Destination.CardID
+	}
+	return txn.Destination.Description
+}
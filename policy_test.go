@@ -0,0 +1,339 @@
+package uphold
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// policyServer fakes the transactions of card c1 and counts the requests.
+// Quotes take their amount in USD from the card, at 1000 USD per BTC, and
+// the commits of the transactions in refused fail.
+func policyServer(t *testing.T) *int {
+	var mu sync.Mutex
+	calls, quotes := 0, 0
+	refused := map[string]bool{"t-refused": true}
+
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if r.URL.Query().Get("commit") != "" {
+			t.Error("quote was committed before being checked")
+		}
+
+		var q Quote
+		json.NewDecoder(r.Body).Decode(&q)
+		amount := q.Denomination.Amount
+		if q.Denomination.Currency == CurrencyBTC {
+			amount *= 1000
+		}
+
+		mu.Lock()
+		calls++
+		quotes++
+		id := fmt.Sprintf("t%d", quotes)
+		if q.Destination == "refused@example.com" {
+			id = "t-refused"
+		}
+		mu.Unlock()
+
+		fmt.Fprintf(w, `{"id":"%s","status":"pending","denomination":{"amount":"%g","currency":"%s"},"origin":{"amount":"%g","currency":"USD"}}`,
+			id, q.Denomination.Amount, q.Denomination.Currency, amount)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/me/cards/c1/transactions/"), "/commit")
+
+		mu.Lock()
+		calls++
+		mu.Unlock()
+
+		if refused[id] {
+			http.Error(w, `{"code":"validation_failed"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"id":"%s","status":"completed"}`, id)
+	})
+	mux.HandleFunc("/me/contacts", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":"k1","emails":["friend@example.com","refused@example.com"]}]`)
+	})
+	return &calls
+}
+
+func usdQuote(amount float32, dest string) Quote {
+	return Quote{Denomination: &QuoteDenomination{Amount: amount, Currency: CurrencyUSD}, Destination: dest}
+}
+
+func violatedRule(err error) PolicyRule {
+	if v, ok := err.(PolicyViolationError); ok {
+		return v.Rule
+	}
+	return ""
+}
+
+func TestPolicyMaxAmount(t *testing.T) {
+	setup()
+	defer teardown()
+	calls := policyServer(t)
+
+	client.SetPolicy(&Policy{MaxAmount: map[CurrencyCode]float32{CurrencyUSD: 50}})
+	card := Card{ID: "c1"}
+
+	if _, _, err := client.Transaction.Create(card, usdQuote(60, "friend@example.com")); violatedRule(err) != RuleMaxAmount {
+		t.Errorf("Create() returned %v, want a max amount violation", err)
+	}
+	if *calls != 0 {
+		t.Errorf("rejected transaction made %d requests", *calls)
+	}
+	if _, _, err := client.Transaction.Create(card, usdQuote(40, "friend@example.com")); err != nil {
+		t.Errorf("Create() returned unexpected error: %v", err)
+	}
+
+	// 0.1 BTC takes 100 USD from the card
+	q := Quote{Denomination: &QuoteDenomination{Amount: 0.1, Currency: CurrencyBTC}, Destination: "friend@example.com"}
+	if _, _, err := client.Transaction.Create(card, q); violatedRule(err) != RuleMaxAmount {
+		t.Errorf("Create() of 0.1 BTC returned %v, want a max amount violation on the origin amount", err)
+	}
+}
+
+func TestPolicyDestination(t *testing.T) {
+	setup()
+	defer teardown()
+	policyServer(t)
+
+	client.SetPolicy(&Policy{AllowedDestinations: []string{"c2"}, AllowContacts: true})
+	card := Card{ID: "c1"}
+
+	for _, dest := range []string{"c2", "Friend@example.com"} {
+		if _, _, err := client.Transaction.Create(card, usdQuote(10, dest)); err != nil {
+			t.Errorf("Create() to %s returned unexpected error: %v", dest, err)
+		}
+	}
+	if _, _, err := client.Transaction.Create(card, usdQuote(10, "stranger@example.com")); violatedRule(err) != RuleDestination {
+		t.Errorf("Create() returned %v, want a destination violation", err)
+	}
+}
+
+func TestPolicyDestinationTag(t *testing.T) {
+	setup()
+	defer teardown()
+	policyServer(t)
+
+	const address = "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh"
+	card := Card{ID: "c1", Currency: "USD"}
+	withdraw := func(memo string) error {
+		_, _, err := client.Transaction.Withdraw(card, Withdrawal{Network: NetworkXRP, Address: address, Memo: memo, Amount: 10})
+		return err
+	}
+
+	// an allowed address allows any destination tag
+	client.SetPolicy(&Policy{AllowedDestinations: []string{address}})
+	for _, memo := range []string{"", "12345"} {
+		if err := withdraw(memo); err != nil {
+			t.Errorf("Withdraw() with tag %q returned unexpected error: %v", memo, err)
+		}
+	}
+
+	client.SetPolicy(&Policy{AllowedDestinations: []string{address + "?dt=12345"}})
+	if err := withdraw("12345"); err != nil {
+		t.Errorf("Withdraw() with the allowed tag returned unexpected error: %v", err)
+	}
+	if err := withdraw("999"); violatedRule(err) != RuleDestination {
+		t.Errorf("Withdraw() with another tag returned %v, want a destination violation", err)
+	}
+}
+
+func TestPolicyWindowLimit(t *testing.T) {
+	setup()
+	defer teardown()
+	policyServer(t)
+
+	now := time.Date(2016, 10, 12, 10, 0, 0, 0, time.UTC)
+	p := &Policy{
+		Limits: []WindowLimit{{Currency: CurrencyUSD, Amount: 100, Window: 24 * time.Hour}},
+		now:    func() time.Time { return now },
+	}
+	client.SetPolicy(p)
+	card := Card{ID: "c1"}
+
+	first, _, err := client.Transaction.Create(card, usdQuote(60, "c2"))
+	if err != nil {
+		t.Fatalf("Create() returned unexpected error: %v", err)
+	}
+	second, _, err := client.Transaction.Create(card, usdQuote(60, "c2"))
+	if err != nil {
+		t.Fatalf("Create() returned unexpected error: %v", err)
+	}
+
+	if _, _, err := client.Transaction.Commit(card, *first, ""); err != nil {
+		t.Fatalf("Commit() returned unexpected error: %v", err)
+	}
+	if _, _, err := client.Transaction.Commit(card, *second, ""); violatedRule(err) != RuleWindowLimit {
+		t.Errorf("second Commit() returned %v, want a window limit violation", err)
+	}
+	if _, _, err := client.Transaction.Commit(card, *first, ""); violatedRule(err) != RuleUnknownTransaction {
+		t.Errorf("committing again returned %v, want an unknown transaction violation", err)
+	}
+
+	now = now.Add(25 * time.Hour)
+	txn, _, err := client.Transaction.Create(card, usdQuote(60, "c2"))
+	if err != nil {
+		t.Fatalf("Create() after the window returned unexpected error: %v", err)
+	}
+	if _, _, err := client.Transaction.Commit(card, *txn, ""); err != nil {
+		t.Errorf("Commit() after the window returned unexpected error: %v", err)
+	}
+}
+
+func TestPolicyWindowReservation(t *testing.T) {
+	setup()
+	defer teardown()
+	policyServer(t)
+
+	client.SetPolicy(&Policy{
+		Limits:        []WindowLimit{{Currency: CurrencyUSD, Amount: 100, Window: time.Hour}},
+		AllowContacts: true,
+	})
+	card := Card{ID: "c1"}
+
+	// a refused commit releases its reservation
+	refused, _, err := client.Transaction.Create(card, usdQuote(60, "refused@example.com"))
+	if err != nil {
+		t.Fatalf("Create() returned unexpected error: %v", err)
+	}
+	if _, _, err := client.Transaction.Commit(card, *refused, ""); err == nil {
+		t.Fatal("Commit() of a refused transaction returned no error")
+	}
+
+	var txns []*Txn
+	for i := 0; i < 2; i++ {
+		txn, _, err := client.Transaction.Create(card, usdQuote(60, "friend@example.com"))
+		if err != nil {
+			t.Fatalf("Create() returned unexpected error: %v", err)
+		}
+		txns = append(txns, txn)
+	}
+
+	errs := make(chan error, len(txns))
+	for _, txn := range txns {
+		go func(txn Txn) {
+			_, _, err := client.Transaction.Commit(card, txn, "")
+			errs <- err
+		}(*txn)
+	}
+
+	var committed, limited int
+	for range txns {
+		switch err := <-errs; {
+		case err == nil:
+			committed++
+		case violatedRule(err) == RuleWindowLimit:
+			limited++
+		default:
+			t.Errorf("Commit() returned unexpected error: %v", err)
+		}
+	}
+	if committed != 1 || limited != 1 {
+		t.Errorf("concurrent commits made %d and were limited %d times, want 1 and 1", committed, limited)
+	}
+}
+
+func TestPolicyUnknownTransaction(t *testing.T) {
+	setup()
+	defer teardown()
+	calls := policyServer(t)
+
+	client.SetPolicy(&Policy{})
+	if _, _, err := client.Transaction.Commit(Card{ID: "c1"}, Txn{ID: "t1"}, ""); violatedRule(err) != RuleUnknownTransaction {
+		t.Errorf("Commit() of an unknown transaction returned %v, want an unknown transaction violation", err)
+	}
+	if *calls != 0 {
+		t.Errorf("unknown transaction made %d requests", *calls)
+	}
+}
+
+func TestPolicyRealtime(t *testing.T) {
+	setup()
+	defer teardown()
+	calls := policyServer(t)
+
+	client.SetPolicy(&Policy{MaxAmount: map[CurrencyCode]float32{CurrencyUSD: 50}})
+	card := Card{ID: "c1"}
+
+	q := usdQuote(40, "c2")
+	q.Realtime = true
+	txn, _, err := client.Transaction.Create(card, q)
+	if err != nil {
+		t.Fatalf("Create() returned unexpected error: %v", err)
+	}
+	if txn.Status != TxnStatusCompleted || *calls != 2 {
+		t.Errorf("realtime Create() returned %+v after %d requests, want a commit after the quote", txn, *calls)
+	}
+
+	q = Quote{Denomination: &QuoteDenomination{Amount: 0.1, Currency: CurrencyBTC}, Destination: "c2", Realtime: true}
+	if _, _, err := client.Transaction.Create(card, q); violatedRule(err) != RuleMaxAmount {
+		t.Errorf("realtime Create() of 0.1 BTC returned %v, want a max amount violation", err)
+	}
+	if *calls != 3 {
+		t.Errorf("refused realtime quote made %d requests, want the quote only", *calls-2)
+	}
+}
+
+func TestPolicyBusinessHours(t *testing.T) {
+	setup()
+	defer teardown()
+	policyServer(t)
+
+	now := time.Date(2016, 10, 15, 10, 0, 0, 0, time.UTC) // a Saturday
+	client.SetPolicy(&Policy{
+		BusinessHours: &BusinessHours{Start: 9, End: 17},
+		now:           func() time.Time { return now },
+	})
+
+	if _, _, err := client.Transaction.Create(Card{ID: "c1"}, usdQuote(10, "c2")); violatedRule(err) != RuleBusinessHours {
+		t.Errorf("Create() on a Saturday returned %v, want a business hours violation", err)
+	}
+
+	now = time.Date(2016, 10, 17, 10, 0, 0, 0, time.UTC)
+	if _, _, err := client.Transaction.Create(Card{ID: "c1"}, usdQuote(10, "c2")); err != nil {
+		t.Errorf("Create() on a Monday returned unexpected error: %v", err)
+	}
+}
+
+func TestPolicyConfirmation(t *testing.T) {
+	setup()
+	defer teardown()
+	calls := policyServer(t)
+
+	var asked []PolicyRequest
+	confirm := false
+	client.SetPolicy(&Policy{
+		ConfirmAbove: map[CurrencyCode]float32{CurrencyUSD: 50},
+		Confirm: func(r PolicyRequest) (bool, error) {
+			asked = append(asked, r)
+			return confirm, nil
+		},
+	})
+	card := Card{ID: "c1"}
+
+	txn, _, err := client.Transaction.Create(card, usdQuote(60, "c2"))
+	if err != nil {
+		t.Fatalf("Create() returned unexpected error: %v", err)
+	}
+	if _, _, err := client.Transaction.Commit(card, *txn, ""); violatedRule(err) != RuleConfirmation {
+		t.Errorf("Commit() returned %v, want a confirmation violation", err)
+	}
+	if *calls != 1 {
+		t.Errorf("unconfirmed commit was sent")
+	}
+
+	confirm = true
+	if _, _, err := client.Transaction.Commit(card, *txn, ""); err != nil {
+		t.Errorf("confirmed Commit() returned unexpected error: %v", err)
+	}
+	if len(asked) != 2 || asked[0].Amount != 60 || asked[0].Currency != CurrencyUSD {
+		t.Errorf("Confirm was asked %+v", asked)
+	}
+}
@@ -0,0 +1,194 @@
+package uphold
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// DefaultIdleTimeout is how long a ClientPool keeps unused clients
+const DefaultIdleTimeout = 30 * time.Minute
+
+// pooledClient is a client of a ClientPool
+type pooledClient struct {
+	client   *Client
+	lastUsed time.Time
+}
+
+// ClientPool manages one client per user, so that each user keeps its
+// own rate limit tracking across requests. The clients share the
+// transport, and so the connections, and are created on first use with
+// the token loaded from the store of the user.
+type ClientPool struct {
+	// Config refreshes the tokens of the users
+	Config *oauth2.Config
+
+	// Tokens returns the store of the token of a user
+	Tokens func(user string) (TokenStore, error)
+
+	// Transport is shared by all the clients,
+	// http.DefaultTransport is used if nil
+	Transport http.RoundTripper
+
+	// IdleTimeout is how long unused clients are kept,
+	// DefaultIdleTimeout is used if zero
+	IdleTimeout time.Duration
+
+	// Setup, if set, is called with every new client,
+	// for instance to use the sandbox or add middleware
+	Setup func(user string, c *Client) error
+
+	mu        sync.Mutex
+	clients   map[string]*pooledClient
+	lastSweep time.Time
+}
+
+// NewClientPool returns a pool of clients authorized with the tokens
+// in the stores returned by tokens, refreshed with conf
+func NewClientPool(conf *oauth2.Config, tokens func(user string) (TokenStore, error)) *ClientPool {
+	return &ClientPool{
+		Config:  conf,
+		Tokens:  tokens,
+		clients: map[string]*pooledClient{},
+	}
+}
+
+// idleTimeout returns the idle timeout in use
+func (p *ClientPool) idleTimeout() time.Duration {
+	if p.IdleTimeout == 0 {
+		return DefaultIdleTimeout
+	}
+	return p.IdleTimeout
+}
+
+// Get returns the client of the user, creating it if needed. ErrNoToken
+// is returned if the store of the user has no token.
+func (p *ClientPool) Get(user string) (*Client, error) {
+	now := time.Now()
+
+	p.mu.Lock()
+	if now.Sub(p.lastSweep) >= p.idleTimeout()/2 {
+		p.evict(now)
+	}
+	if pc, ok := p.clients[user]; ok {
+		pc.lastUsed = now
+		p.mu.Unlock()
+		return pc.client, nil
+	}
+	p.mu.Unlock()
+
+	c, err := p.newClient(user)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// another goroutine may have created the client meanwhile
+	if pc, ok := p.clients[user]; ok {
+		pc.lastUsed = now
+		return pc.client, nil
+	}
+	if p.clients == nil {
+		p.clients = map[string]*pooledClient{}
+	}
+	p.clients[user] = &pooledClient{client: c, lastUsed: now}
+	return c, nil
+}
+
+// newClient creates the client of the user
+func (p *ClientPool) newClient(user string) (*Client, error) {
+	store, err := p.Tokens(user)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	base := p.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	c := NewClient(&http.Client{
+		Transport: &oauth2.Transport{
+			Source: NewTokenSource(p.Config, store),
+			Base:   base,
+		},
+	})
+	c.SetGrantedScopes(ParseScopes(tok))
+
+	if p.Setup != nil {
+		if err := p.Setup(user, c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Remove discards the client of the user, for instance
+// after the user revoked the authorization
+func (p *ClientPool) Remove(user string) {
+	p.mu.Lock()
+	delete(p.clients, user)
+	p.mu.Unlock()
+}
+
+// Len returns the number of clients in the pool
+func (p *ClientPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// Rate returns the rate limit of the user as determined by the most
+// recent request of its client, false if the user has no client
+func (p *ClientPool) Rate(user string) (RequestRate, bool) {
+	p.mu.Lock()
+	pc, ok := p.clients[user]
+	p.mu.Unlock()
+
+	if !ok {
+		return RequestRate{}, false
+	}
+	return pc.client.Rate(), true
+}
+
+// Rates returns the rate limits of all the users with a client
+func (p *ClientPool) Rates() map[string]RequestRate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rates := make(map[string]RequestRate, len(p.clients))
+	for user, pc := range p.clients {
+		rates[user] = pc.client.Rate()
+	}
+	return rates
+}
+
+// EvictIdle discards the clients unused for longer than
+// the idle timeout and returns how many were discarded.
+// Idle clients are also evicted regularly by Get.
+func (p *ClientPool) EvictIdle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.evict(time.Now())
+}
+
+// evict discards the idle clients, p.mu must be held
+func (p *ClientPool) evict(now time.Time) int {
+	n := 0
+	for user, pc := range p.clients {
+		if now.Sub(pc.lastUsed) > p.idleTimeout() {
+			delete(p.clients, user)
+			n++
+		}
+	}
+	p.lastSweep = now
+	return n
+}
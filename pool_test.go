@@ -0,0 +1,109 @@
+package uphold
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func newTestPool() *ClientPool {
+	stores := map[string]TokenStore{
+		"alice": NewMemoryTokenStore(&oauth2.Token{AccessToken: "alice-token"}),
+		"bob":   NewMemoryTokenStore(&oauth2.Token{AccessToken: "bob-token"}),
+		"carol": NewMemoryTokenStore(nil),
+	}
+
+	p := NewClientPool(&oauth2.Config{}, func(user string) (TokenStore, error) {
+		return stores[user], nil
+	})
+	p.Setup = func(user string, c *Client) error {
+		return c.SetAPIURL(server.URL)
+	}
+	return p
+}
+
+func TestClientPool(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		remaining := "10"
+		if r.Header.Get("Authorization") == "Bearer bob-token" {
+			remaining = "20"
+		}
+		w.Header().Set(headerRateRemaining, remaining)
+		w.Write([]byte(`[]`))
+	})
+
+	p := newTestPool()
+	for _, user := range []string{"alice", "bob"} {
+		c, err := p.Get(user)
+		if err != nil {
+			t.Fatalf("Get(%q) returned unexpected error: %v", user, err)
+		}
+		if _, _, err := c.Card.ListAll(); err != nil {
+			t.Fatalf("Card.ListAll() returned unexpected error: %v", err)
+		}
+	}
+
+	a1, _ := p.Get("alice")
+	a2, _ := p.Get("alice")
+	b, _ := p.Get("bob")
+	if a1 != a2 || a1 == b {
+		t.Error("Get() should reuse the client of the user and not share it with others")
+	}
+
+	if r, ok := p.Rate("alice"); !ok || r.Remaining != 10 {
+		t.Errorf("Rate(alice) returned %+v, %v, want 10 remaining", r, ok)
+	}
+	if r := p.Rates()["bob"]; r.Remaining != 20 {
+		t.Errorf("Rates()[bob] is %+v, want 20 remaining", r)
+	}
+
+	if _, err := p.Get("carol"); err != ErrNoToken {
+		t.Errorf("Get() for user without token returned %v, want ErrNoToken", err)
+	}
+}
+
+func TestClientPoolLiteral(t *testing.T) {
+	p := &ClientPool{
+		Config: &oauth2.Config{},
+		Tokens: func(user string) (TokenStore, error) {
+			return NewMemoryTokenStore(&oauth2.Token{AccessToken: "token"}), nil
+		},
+	}
+
+	c, err := p.Get("alice")
+	if err != nil {
+		t.Fatalf("Get() returned unexpected error: %v", err)
+	}
+	if again, _ := p.Get("alice"); again != c {
+		t.Error("Get() should reuse the client of the user")
+	}
+}
+
+func TestClientPoolEvictIdle(t *testing.T) {
+	setup()
+	defer teardown()
+
+	p := newTestPool()
+	p.IdleTimeout = time.Millisecond
+
+	p.Get("alice")
+	time.Sleep(5 * time.Millisecond)
+	p.Get("bob")
+
+	if _, ok := p.Rate("alice"); ok {
+		t.Error("idle client should be evicted by Get")
+	}
+	if p.Len() != 1 {
+		t.Errorf("Len() is %d, want 1", p.Len())
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if n := p.EvictIdle(); n != 1 || p.Len() != 0 {
+		t.Errorf("EvictIdle() returned %d leaving %d clients, want 1 leaving none", n, p.Len())
+	}
+}
@@ -0,0 +1,94 @@
+package uphold
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// attemptKey is the context key of the attempt number of a request
+type attemptKey struct{}
+
+// ContextWithAttempt returns a copy of ctx marking the n-th attempt
+// of a request, for middleware which retries requests
+func ContextWithAttempt(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, n)
+}
+
+// RequestAttempt returns the attempt number of the request,
+// 1 unless it was marked otherwise with ContextWithAttempt
+func RequestAttempt(req *http.Request) int {
+	if n, ok := req.Context().Value(attemptKey{}).(int); ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// RetryMiddleware sends a request up to attempts times. Rate limited
+// requests are retried after the delay given by the API. Idempotent
+// requests are also retried on server and network errors, waiting
+// backoff multiplied by the attempt number. Each attempt is marked
+// with ContextWithAttempt for the middleware added after this one.
+func RetryMiddleware(attempts int, backoff time.Duration) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			var body []byte
+			if req.Body != nil {
+				var err error
+				if body, err = ioutil.ReadAll(req.Body); err != nil {
+					return nil, err
+				}
+				req.Body.Close()
+			}
+
+			ctx := req.Context()
+			for n := 1; ; n++ {
+				r := req.WithContext(ContextWithAttempt(ctx, n))
+				if body != nil {
+					r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				}
+
+				resp, err := next.Do(r, v)
+				if err == nil || n >= attempts {
+					return resp, err
+				}
+
+				wait, ok := retryDelay(req.Method, resp, err)
+				if !ok {
+					return resp, err
+				}
+				if wait == 0 {
+					wait = backoff * time.Duration(n)
+				}
+
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return resp, err
+				}
+			}
+		})
+	}
+}
+
+// retryDelay reports whether a failed request can be retried and how long
+// to wait before, zero meaning the default backoff
+func retryDelay(method string, resp *Response, err error) (time.Duration, bool) {
+	if e, ok := err.(RateLimitError); ok {
+		return time.Duration(e.RetryAfter) * time.Second, true
+	}
+
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+	default:
+		return 0, false
+	}
+
+	if _, ok := err.(ErrorResponse); ok {
+		return 0, resp.StatusCode >= 500
+	}
+	// the request never reached the API
+	return 0, resp == nil
+}
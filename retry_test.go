@@ -0,0 +1,81 @@
+package uphold
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryMiddlewareRateLimit(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var attempts []string
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		testBody(t, r, `{"currency":"USD","label":"label"}`)
+		attempts = append(attempts, r.Method)
+		if len(attempts) == 1 {
+			w.Header().Set(headerRateRemaining, "0")
+			w.Header().Set(headerRetryAfter, "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id":"1"}`))
+	})
+
+	client.Use(RetryMiddleware(3, time.Millisecond))
+
+	card, _, err := client.Card.Add(Card{Label: "label", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("Card.Add() returned unexpected error: %v", err)
+	}
+	if card.ID != "1" || len(attempts) != 2 {
+		t.Errorf("Card.Add() returned %+v after %d attempts, want card 1 after 2", card, len(attempts))
+	}
+}
+
+func TestRetryMiddlewareNotIdempotent(t *testing.T) {
+	setup()
+	defer teardown()
+
+	attempts := 0
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	client.Use(RetryMiddleware(3, time.Millisecond))
+
+	if _, _, err := client.Card.Add(Card{Label: "label", Currency: "USD"}); err == nil {
+		t.Error("Card.Add() should return an error")
+	}
+	if attempts != 1 {
+		t.Errorf("POST was sent %d times, want 1", attempts)
+	}
+}
+
+func TestRetryMiddlewareAttempts(t *testing.T) {
+	setup()
+	defer teardown()
+
+	attempts := 0
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	var marked []int
+	client.Use(RetryMiddleware(3, time.Millisecond), func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			marked = append(marked, RequestAttempt(req))
+			return next.Do(req, v)
+		})
+	})
+
+	if _, _, err := client.Card.ListAll(); err == nil {
+		t.Error("Card.ListAll() should return an error")
+	}
+	if attempts != 3 || len(marked) != 3 || marked[2] != 3 {
+		t.Errorf("sent %d attempts marked %v, want 3 attempts marked 1 to 3", attempts, marked)
+	}
+}
@@ -0,0 +1,575 @@
+package uphold
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultLateTolerance is how late a run may start before
+// it is considered missed
+const DefaultLateTolerance = time.Minute
+
+// maxCatchUpRuns limits the runs made up by MissedRunAll
+const maxCatchUpRuns = 100
+
+// MissedRunPolicy decides what happens to the runs missed
+// while the scheduler was not running
+type MissedRunPolicy int
+
+// Missed run policies
+const (
+	// MissedRunSkip drops the missed runs
+	MissedRunSkip MissedRunPolicy = iota
+
+	// MissedRunOnce makes a single run for all the missed ones
+	MissedRunOnce
+
+	// MissedRunAll makes up every missed run
+	MissedRunAll
+)
+
+// StandingOrder is a recurring transfer
+type StandingOrder struct {
+	ID string
+
+	// Schedule in the syntax of ParseSchedule
+	Schedule string
+
+	// Card is the origin of the transfers
+	Card Card
+
+	// Destination is an email, a card ID or a crypto address
+	Destination string
+
+	Amount   float32
+	Currency CurrencyCode
+	Message  string
+
+	// MaxSlippage, if not zero, is the largest fraction by which the
+	// amount received may fall short of the amount expected at the
+	// ticker rate, fees included. Transfers quoted worse are not committed.
+	MaxSlippage float64
+
+	Missed MissedRunPolicy
+}
+
+// RunState is the persisted state of a standing order
+type RunState struct {
+	NextRun       time.Time `json:"nextRun"`
+	LastRun       time.Time `json:"lastRun,omitempty"`
+	LastStatus    RunStatus `json:"lastStatus,omitempty"`
+	TransactionID string    `json:"transactionId,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// RunStateStore persists the state of the standing orders
+type RunStateStore interface {
+	// Load returns the state of the order, a zero state if unknown
+	Load(id string) (RunState, error)
+	Save(id string, s RunState) error
+}
+
+// MemoryRunStateStore keeps the run states in memory
+type MemoryRunStateStore struct {
+	mu     sync.Mutex
+	states map[string]RunState
+}
+
+// NewMemoryRunStateStore returns an empty memory run state store
+func NewMemoryRunStateStore() *MemoryRunStateStore {
+	return &MemoryRunStateStore{states: map[string]RunState{}}
+}
+
+// Load returns the state of the order
+func (m *MemoryRunStateStore) Load(id string) (RunState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.states[id], nil
+}
+
+// Save records the state of the order
+func (m *MemoryRunStateStore) Save(id string, s RunState) error {
+	m.mu.Lock()
+	m.states[id] = s
+	m.mu.Unlock()
+	return nil
+}
+
+// FileRunStateStore keeps the run states in a JSON file,
+// rewritten atomically on every save
+type FileRunStateStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileRunStateStore returns a store keeping the run states at path
+func NewFileRunStateStore(path string) *FileRunStateStore {
+	return &FileRunStateStore{path: path}
+}
+
+// read returns all the states in the file, f.mu must be held
+func (f *FileRunStateStore) read() (map[string]RunState, error) {
+	states := map[string]RunState{}
+
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return states, json.Unmarshal(b, &states)
+}
+
+// Load returns the state of the order
+func (f *FileRunStateStore) Load(id string) (RunState, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	states, err := f.read()
+	if err != nil {
+		return RunState{}, err
+	}
+	return states[id], nil
+}
+
+// Save records the state of the order
+func (f *FileRunStateStore) Save(id string, s RunState) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	states, err := f.read()
+	if err != nil {
+		return err
+	}
+	states[id] = s
+
+	b, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, b, 0600)
+}
+
+// RunStatus is the outcome of a run of a standing order
+type RunStatus string
+
+// Outcomes of runs
+const (
+	RunCompleted RunStatus = "completed"
+	RunFailed    RunStatus = "failed"
+
+	// RunSkipped runs were missed or quoted beyond the slippage
+	RunSkipped RunStatus = "skipped"
+
+	// RunSimulated runs were quoted but not committed in dry-run mode,
+	// their state is never saved
+	RunSimulated RunStatus = "simulated"
+
+	// RunCommitting is saved before a transfer is committed. An order
+	// found in this status when added has an unknown outcome.
+	RunCommitting RunStatus = "committing"
+
+	// RunUnknown runs may or may not have been transferred
+	// and must be checked against the card history
+	RunUnknown RunStatus = "unknown"
+)
+
+// SlippageError is returned when a quote exceeds the
+// slippage allowed by a standing order
+type SlippageError struct {
+	Expected float32
+	Quoted   float32
+	Currency string
+}
+
+// Error returns the string representation of the error
+func (e SlippageError) Error() string {
+	return fmt.Sprintf("uphold: quote of %g %s is below the expected %g %s beyond the allowed slippage",
+		e.Quoted, e.Currency, e.Expected, e.Currency)
+}
+
+// RunResult is the outcome of a run of a standing order
+type RunResult struct {
+	OrderID     string
+	Scheduled   time.Time
+	Status      RunStatus
+	Transaction *Txn
+
+	// Err is the error of the run, or the error saving the state
+	// of a run which otherwise succeeded
+	Err error
+}
+
+// scheduledOrder is a standing order with its parsed schedule and state
+type scheduledOrder struct {
+	StandingOrder
+	schedule Schedule
+
+	// state is guarded by the mutex of the scheduler, and only
+	// changed by the RunDue call which set running
+	state   RunState
+	running bool
+}
+
+// Scheduler runs standing orders on their schedules
+type Scheduler struct {
+	Client *Client
+	State  RunStateStore
+
+	// DryRun quotes the transfers without committing them,
+	// and without saving the run states
+	DryRun bool
+
+	// LateTolerance is how late a run may start before it is considered
+	// missed, DefaultLateTolerance is used if zero
+	LateTolerance time.Duration
+
+	// OnRun, if set, is called with the result of every run
+	OnRun func(r RunResult)
+
+	mu     sync.Mutex
+	orders []*scheduledOrder
+}
+
+// NewScheduler returns a scheduler running transfers with c
+// and persisting the run states in state
+func NewScheduler(c *Client, state RunStateStore) *Scheduler {
+	return &Scheduler{Client: c, State: state}
+}
+
+// Add schedules a standing order, resuming from its saved state
+func (s *Scheduler) Add(o StandingOrder) error {
+	if o.ID == "" {
+		return errors.New("uphold: standing order without ID")
+	}
+	if o.Destination == "" || o.Amount <= 0 {
+		return fmt.Errorf("uphold: standing order %s needs a destination and a positive amount", o.ID)
+	}
+
+	if o.Currency == "" {
+		o.Currency = CurrencyCode(o.Card.Currency)
+	}
+	if o.Currency == "" {
+		return fmt.Errorf("uphold: standing order %s has no currency", o.ID)
+	}
+
+	sched, err := ParseSchedule(o.Schedule)
+	if err != nil {
+		return err
+	}
+	state, err := s.State.Load(o.ID)
+	if err != nil {
+		return err
+	}
+	if state.LastStatus == RunCommitting {
+		state.LastStatus = RunUnknown
+		state.Error = "interrupted while committing, check the card transactions"
+		if !s.dryRun() {
+			if err := s.State.Save(o.ID, state); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.orders {
+		if e.ID == o.ID {
+			return fmt.Errorf("uphold: standing order %s already scheduled", o.ID)
+		}
+	}
+	s.orders = append(s.orders, &scheduledOrder{StandingOrder: o, schedule: sched, state: state})
+	return nil
+}
+
+// NextRun returns the earliest time a standing order is due,
+// or the zero time if no order is scheduled
+func (s *Scheduler) NextRun(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	for _, o := range s.orders {
+		t := o.state.NextRun
+		if t.IsZero() {
+			t = o.schedule.Next(now)
+		}
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+	return next
+}
+
+// RunDue runs the standing orders due at now and returns their results.
+// Orders seen for the first time are scheduled from now on. The state of
+// an order is saved after each run, and the order stops running if it
+// cannot be saved.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) []RunResult {
+	var results []RunResult
+	var due []*scheduledOrder
+
+	s.mu.Lock()
+	for _, o := range s.orders {
+		if o.running {
+			continue
+		}
+		if o.state.NextRun.IsZero() {
+			next := o.schedule.Next(now)
+			if err := s.save(o.ID, RunState{NextRun: next}); err != nil {
+				results = append(results, RunResult{OrderID: o.ID, Scheduled: next, Status: RunFailed, Err: err})
+				continue
+			}
+			o.state.NextRun = next
+			continue
+		}
+		if now.Before(o.state.NextRun) {
+			continue
+		}
+		o.running = true
+		due = append(due, o)
+	}
+	s.mu.Unlock()
+
+	for _, r := range results {
+		s.report(r)
+	}
+
+	for _, o := range due {
+		results = append(results, s.runOrder(ctx, o, now)...)
+
+		s.mu.Lock()
+		o.running = false
+		s.mu.Unlock()
+	}
+	return results
+}
+
+// runOrder makes the runs of the order due at now. The caller
+// has set o.running, so o.state can be read without the lock.
+func (s *Scheduler) runOrder(ctx context.Context, o *scheduledOrder, now time.Time) []RunResult {
+	tolerance := s.LateTolerance
+	if tolerance == 0 {
+		tolerance = DefaultLateTolerance
+	}
+
+	// the occurrences due, the last one being the latest before now
+	var due []time.Time
+	for t := o.state.NextRun; !t.IsZero() && !t.After(now); t = o.schedule.Next(t) {
+		due = append(due, t)
+		if len(due) > maxCatchUpRuns {
+			due = due[1:]
+		}
+	}
+
+	var results []RunResult
+	var runs []time.Time
+	last := due[len(due)-1]
+	switch o.Missed {
+	case MissedRunAll:
+		runs = due
+	case MissedRunOnce:
+		runs = due[len(due)-1:]
+	default:
+		for _, t := range due[:len(due)-1] {
+			results = append(results, s.report(RunResult{OrderID: o.ID, Scheduled: t, Status: RunSkipped, Err: errors.New("uphold: run missed")}))
+		}
+		if now.Sub(last) <= tolerance {
+			runs = due[len(due)-1:]
+		} else {
+			results = append(results, s.report(RunResult{OrderID: o.ID, Scheduled: last, Status: RunSkipped, Err: errors.New("uphold: run missed")}))
+		}
+	}
+
+	for _, t := range runs {
+		if ctx.Err() != nil {
+			return results
+		}
+
+		r := s.run(ctx, o, t)
+		err := s.update(o, func(st *RunState) {
+			st.LastRun = t
+			st.LastStatus = r.Status
+			st.TransactionID, st.Error = "", ""
+			if r.Transaction != nil {
+				st.TransactionID = r.Transaction.ID
+			}
+			if r.Err != nil {
+				st.Error = r.Err.Error()
+			}
+			st.NextRun = o.schedule.Next(t)
+		})
+		if err != nil && r.Err == nil {
+			r.Err = err
+		}
+		results = append(results, s.report(r))
+		if err != nil {
+			return results
+		}
+	}
+
+	if err := s.update(o, func(st *RunState) { st.NextRun = o.schedule.Next(now) }); err != nil {
+		results = append(results, s.report(RunResult{OrderID: o.ID, Scheduled: last, Status: RunFailed, Err: err}))
+	}
+	return results
+}
+
+// report passes the result to OnRun
+func (s *Scheduler) report(r RunResult) RunResult {
+	if s.OnRun != nil {
+		s.OnRun(r)
+	}
+	return r
+}
+
+// update changes the state of the order with f and saves it. The state
+// is only changed in memory if it was saved.
+func (s *Scheduler) update(o *scheduledOrder, f func(st *RunState)) error {
+	st := o.state
+	f(&st)
+
+	if err := s.save(o.ID, st); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	o.state = st
+	s.mu.Unlock()
+	return nil
+}
+
+// dryRun reports whether the scheduler or its client is in dry-run mode
+func (s *Scheduler) dryRun() bool {
+	return s.DryRun || s.Client.DryRun()
+}
+
+// save persists the state of an order unless in dry-run mode
+func (s *Scheduler) save(id string, st RunState) error {
+	if s.dryRun() {
+		return nil
+	}
+	if err := s.State.Save(id, st); err != nil {
+		return fmt.Errorf("uphold: cannot save the state of standing order %s: %s", id, err)
+	}
+	return nil
+}
+
+// run quotes, checks and commits one transfer of the order
+func (s *Scheduler) run(ctx context.Context, o *scheduledOrder, scheduled time.Time) RunResult {
+	r := RunResult{OrderID: o.ID, Scheduled: scheduled}
+	c := s.Client.WithContext(ctx)
+
+	q := Quote{
+		Denomination: &QuoteDenomination{Amount: o.Amount, Currency: o.Currency},
+		Destination:  o.Destination,
+	}
+	txn, _, err := c.Transaction.Create(o.Card, q)
+	if err != nil {
+		r.Status, r.Err = RunFailed, err
+		return r
+	}
+	r.Transaction = txn
+
+	if o.MaxSlippage > 0 {
+		if err := checkSlippage(c, o.StandingOrder, txn); err != nil {
+			r.Err = err
+			r.Status = RunFailed
+			if _, ok := err.(SlippageError); ok {
+				r.Status = RunSkipped
+			}
+			return r
+		}
+	}
+
+	if s.DryRun {
+		r.Status = RunSimulated
+		return r
+	}
+
+	// a crash while committing must not run the transfer again
+	err = s.update(o, func(st *RunState) {
+		st.LastRun = scheduled
+		st.LastStatus = RunCommitting
+		st.TransactionID, st.Error = txn.ID, ""
+		st.NextRun = o.schedule.Next(scheduled)
+	})
+	if err != nil {
+		r.Status, r.Err = RunFailed, err
+		return r
+	}
+
+	committed, _, err := c.Transaction.Commit(o.Card, *txn, o.Message)
+	if err != nil {
+		r.Status, r.Err = RunFailed, err
+		if commitStatus(err) == PayoutUnknown {
+			r.Status = RunUnknown
+		}
+		return r
+	}
+	r.Status, r.Transaction = RunCompleted, committed
+	if committed.Simulated {
+		r.Status = RunSimulated
+	}
+	return r
+}
+
+// checkSlippage compares the amount received according to the quote
+// with the amount expected at the ticker rate
+func checkSlippage(c *Client, o StandingOrder, txn *Txn) error {
+	from, to := string(o.Currency), txn.Destination.Currency
+	if from == to || to == "" {
+		return nil
+	}
+
+	pairs, _, err := c.Ticker.List(o.Currency)
+	if err != nil {
+		return err
+	}
+
+	var expected float32
+	for _, p := range *pairs {
+		switch p.Pair {
+		case to + from:
+			if p.Ask > 0 {
+				expected = o.Amount / p.Ask
+			}
+		case from + to:
+			expected = o.Amount * p.Bid
+		}
+	}
+	if expected == 0 {
+		return fmt.Errorf("uphold: no ticker rate from %s to %s", from, to)
+	}
+
+	if float64(expected-txn.Destination.Amount) > float64(expected)*o.MaxSlippage {
+		return SlippageError{Expected: expected, Quoted: txn.Destination.Amount, Currency: to}
+	}
+	return nil
+}
+
+// Run runs the standing orders on their schedules until ctx is done
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		now := time.Now()
+		s.RunDue(ctx, now)
+
+		wait := time.Minute
+		if next := s.NextRun(now); !next.IsZero() && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package uphold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// schedulerServer fakes a quote of 100 USD into BTC receiving
+// received BTC, and counts the commits
+func schedulerServer(t *testing.T, received string) *int {
+	commits := 0
+
+	mux.HandleFunc("/ticker/USD", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"pair":"BTCUSD","ask":"1000","bid":"990"}]`)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		fmt.Fprintf(w, `{"id":"t1","status":"pending","destination":{"amount":"%s","currency":"BTC"}}`, received)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/t1/commit", func(w http.ResponseWriter, r *http.Request) {
+		commits++
+		fmt.Fprint(w, `{"id":"t1","status":"completed"}`)
+	})
+	return &commits
+}
+
+var weeklyBuy = StandingOrder{
+	ID:          "dca",
+	Schedule:    "0 9 * * MON",
+	Card:        Card{ID: "c1", Currency: "USD"},
+	Destination: "bc0c4d4c-64f8-4df2-a8f6-cd9d9bb4a91b",
+	Amount:      100,
+	MaxSlippage: 0.05,
+}
+
+func TestSchedulerRunDue(t *testing.T) {
+	setup()
+	defer teardown()
+	commits := schedulerServer(t, "0.0999")
+
+	state := NewMemoryRunStateStore()
+	s := NewScheduler(client, state)
+	if err := s.Add(weeklyBuy); err != nil {
+		t.Fatalf("Add() returned unexpected error: %v", err)
+	}
+
+	now := time.Date(2016, 10, 12, 10, 0, 0, 0, time.UTC)
+	if r := s.RunDue(context.Background(), now); len(r) != 0 {
+		t.Errorf("first RunDue() returned %v, want no run", r)
+	}
+
+	monday := time.Date(2016, 10, 17, 9, 0, 0, 0, time.UTC)
+	if next := s.NextRun(now); !next.Equal(monday) {
+		t.Errorf("NextRun() returned %v, want %v", next, monday)
+	}
+	if r := s.RunDue(context.Background(), monday.Add(-time.Second)); len(r) != 0 {
+		t.Errorf("RunDue() before schedule returned %v, want no run", r)
+	}
+
+	results := s.RunDue(context.Background(), monday.Add(10*time.Second))
+	if len(results) != 1 || results[0].Status != RunCompleted || *commits != 1 {
+		t.Fatalf("RunDue() returned %+v with %d commits, want one completed run", results, *commits)
+	}
+
+	saved, _ := state.Load("dca")
+	if saved.LastStatus != RunCompleted || saved.TransactionID != "t1" || !saved.NextRun.Equal(monday.AddDate(0, 0, 7)) {
+		t.Errorf("saved state is %+v, want completed and next run a week later", saved)
+	}
+}
+
+func TestSchedulerMissedRuns(t *testing.T) {
+	tests := []struct {
+		policy  MissedRunPolicy
+		commits int
+		skipped int
+	}{
+		{MissedRunSkip, 0, 3},
+		{MissedRunOnce, 1, 0},
+		{MissedRunAll, 3, 0},
+	}
+
+	for _, tt := range tests {
+		setup()
+		commits := schedulerServer(t, "0.1")
+
+		state := NewMemoryRunStateStore()
+		state.Save("dca", RunState{NextRun: time.Date(2016, 10, 3, 9, 0, 0, 0, time.UTC)})
+
+		o := weeklyBuy
+		o.Missed = tt.policy
+		s := NewScheduler(client, state)
+		s.Add(o)
+
+		skipped := 0
+		for _, r := range s.RunDue(context.Background(), time.Date(2016, 10, 18, 0, 0, 0, 0, time.UTC)) {
+			if r.Status == RunSkipped {
+				skipped++
+			}
+		}
+		if *commits != tt.commits || skipped != tt.skipped {
+			t.Errorf("policy %d made %d commits and skipped %d runs, want %d and %d", tt.policy, *commits, skipped, tt.commits, tt.skipped)
+		}
+		teardown()
+	}
+}
+
+func TestSchedulerSlippage(t *testing.T) {
+	setup()
+	defer teardown()
+	commits := schedulerServer(t, "0.09")
+
+	state := NewMemoryRunStateStore()
+	state.Save("dca", RunState{NextRun: time.Date(2016, 10, 17, 9, 0, 0, 0, time.UTC)})
+	s := NewScheduler(client, state)
+	s.Add(weeklyBuy)
+
+	results := s.RunDue(context.Background(), time.Date(2016, 10, 17, 9, 0, 0, 0, time.UTC))
+	if len(results) != 1 || results[0].Status != RunSkipped || *commits != 0 {
+		t.Fatalf("RunDue() returned %+v with %d commits, want a skipped run", results, *commits)
+	}
+	if _, ok := results[0].Err.(SlippageError); !ok {
+		t.Errorf("run error is %#v, want SlippageError", results[0].Err)
+	}
+}
+
+func TestSchedulerDryRun(t *testing.T) {
+	setup()
+	defer teardown()
+	commits := schedulerServer(t, "0.1")
+
+	next := time.Date(2016, 10, 17, 9, 0, 0, 0, time.UTC)
+	state := NewMemoryRunStateStore()
+	state.Save("dca", RunState{NextRun: next})
+	s := NewScheduler(client, state)
+	s.DryRun = true
+	s.Add(weeklyBuy)
+
+	var seen []RunResult
+	s.OnRun = func(r RunResult) { seen = append(seen, r) }
+
+	results := s.RunDue(context.Background(), next)
+	if len(results) != 1 || results[0].Status != RunSimulated || *commits != 0 || len(seen) != 1 {
+		t.Errorf("RunDue() returned %+v with %d commits, want a simulated run", results, *commits)
+	}
+	if saved, _ := state.Load("dca"); !saved.NextRun.Equal(next) {
+		t.Errorf("dry run saved state %+v", saved)
+	}
+}
+
+func TestSchedulerClientDryRun(t *testing.T) {
+	setup()
+	defer teardown()
+	commits := schedulerServer(t, "0.1")
+
+	next := time.Date(2016, 10, 17, 9, 0, 0, 0, time.UTC)
+	state := NewMemoryRunStateStore()
+	state.Save("dca", RunState{NextRun: next})
+	client.SetDryRun(true)
+	s := NewScheduler(client, state)
+	s.Add(weeklyBuy)
+
+	results := s.RunDue(context.Background(), next)
+	if len(results) != 1 || results[0].Status != RunSimulated || *commits != 0 {
+		t.Errorf("RunDue() returned %+v with %d commits, want a simulated run", results, *commits)
+	}
+	if saved, _ := state.Load("dca"); !saved.NextRun.Equal(next) || saved.LastStatus != "" {
+		t.Errorf("dry run saved state %+v", saved)
+	}
+}
+
+// failingRunStateStore fails to save once fail is set
+type failingRunStateStore struct {
+	*MemoryRunStateStore
+	fail bool
+}
+
+func (f *failingRunStateStore) Save(id string, s RunState) error {
+	if f.fail {
+		return errors.New("disk full")
+	}
+	return f.MemoryRunStateStore.Save(id, s)
+}
+
+func TestSchedulerCommitMarker(t *testing.T) {
+	setup()
+	defer teardown()
+	schedulerServer(t, "0.1")
+
+	next := time.Date(2016, 10, 17, 9, 0, 0, 0, time.UTC)
+	state := NewMemoryRunStateStore()
+	state.Save("dca", RunState{NextRun: next})
+
+	var marker RunState
+	mux.HandleFunc("/me/cards/c2/transactions/t2/commit", func(w http.ResponseWriter, r *http.Request) {
+		marker, _ = state.Load("dca")
+		http.Error(w, "", http.StatusBadGateway)
+	})
+	mux.HandleFunc("/me/cards/c2/transactions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"t2","status":"pending"}`)
+	})
+
+	o := weeklyBuy
+	o.Card.ID = "c2"
+	s := NewScheduler(client, state)
+	s.Add(o)
+
+	results := s.RunDue(context.Background(), next.Add(10*time.Second))
+	if marker.LastStatus != RunCommitting || marker.TransactionID != "t2" || !marker.NextRun.After(next) {
+		t.Errorf("state while committing is %+v, want committing t2", marker)
+	}
+	if len(results) != 1 || results[0].Status != RunUnknown {
+		t.Errorf("RunDue() returned %+v, want an unknown run", results)
+	}
+
+	saved, _ := state.Load("dca")
+	if saved.LastStatus != RunUnknown || !saved.LastRun.Equal(next) {
+		t.Errorf("saved state is %+v, want unknown with the scheduled time as last run", saved)
+	}
+
+	state.Save("dca", marker)
+	s = NewScheduler(client, state)
+	s.Add(o)
+	if saved, _ := state.Load("dca"); saved.LastStatus != RunUnknown {
+		t.Errorf("interrupted commit resumed as %v, want unknown", saved.LastStatus)
+	}
+}
+
+func TestSchedulerSaveError(t *testing.T) {
+	setup()
+	defer teardown()
+	commits := schedulerServer(t, "0.1")
+
+	state := &failingRunStateStore{MemoryRunStateStore: NewMemoryRunStateStore()}
+	state.Save("dca", RunState{NextRun: time.Date(2016, 10, 3, 9, 0, 0, 0, time.UTC)})
+
+	o := weeklyBuy
+	o.Missed = MissedRunAll
+	s := NewScheduler(client, state)
+	s.Add(o)
+	state.fail = true
+
+	results := s.RunDue(context.Background(), time.Date(2016, 10, 18, 0, 0, 0, 0, time.UTC))
+	if *commits != 0 || len(results) != 1 || results[0].Status != RunFailed || results[0].Err == nil {
+		t.Errorf("RunDue() returned %+v with %d commits, want a single failed run", results, *commits)
+	}
+}
+
+func TestSchedulerCancelSavesRuns(t *testing.T) {
+	setup()
+	defer teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"t1","status":"pending"}`)
+	})
+	mux.HandleFunc("/me/cards/c1/transactions/t1/commit", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":"t1","status":"completed"}`)
+	})
+
+	first := time.Date(2016, 10, 3, 9, 0, 0, 0, time.UTC)
+	state := NewMemoryRunStateStore()
+	state.Save("dca", RunState{NextRun: first})
+
+	o := weeklyBuy
+	o.Missed = MissedRunAll
+	o.MaxSlippage = 0
+	s := NewScheduler(client, state)
+	s.Add(o)
+	s.OnRun = func(r RunResult) { cancel() }
+
+	s.RunDue(ctx, time.Date(2016, 10, 18, 0, 0, 0, 0, time.UTC))
+
+	saved, _ := state.Load("dca")
+	if saved.LastStatus != RunCompleted || !saved.LastRun.Equal(first) || !saved.NextRun.Equal(first.AddDate(0, 0, 7)) {
+		t.Errorf("saved state is %+v, want the first run completed and the second one next", saved)
+	}
+}
@@ -0,0 +1,85 @@
+package uphold
+
+import (
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// MissingScopeError is returned when the token of
+// the client lacks permissions required by a call
+type MissingScopeError struct {
+	Missing []Permission
+}
+
+// Error returns the string representation of the error
+func (e MissingScopeError) Error() string {
+	return "uphold: token lacks required scopes: " + strings.Join(PermissionsToSlice(e.Missing), ", ")
+}
+
+// ParseScopes returns the permissions granted to a token, as
+// listed in the scope field of the token response. It returns
+// nil if the response did not include the scope.
+func ParseScopes(t *oauth2.Token) []Permission {
+	s, ok := t.Extra("scope").(string)
+	if !ok {
+		return nil
+	}
+
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	if len(fields) == 0 {
+		return nil
+	}
+
+	p := make([]Permission, 0, len(fields))
+	for _, f := range fields {
+		p = append(p, Permission(f))
+	}
+	return p
+}
+
+// RequireScopes returns a MissingScopeError listing the
+// required permissions which are not in granted
+func RequireScopes(granted []Permission, required ...Permission) error {
+	has := make(map[Permission]bool, len(granted))
+	for _, p := range granted {
+		has[p] = true
+	}
+
+	var missing []Permission
+	for _, p := range required {
+		if !has[p] {
+			missing = append(missing, p)
+		}
+	}
+
+	if len(missing) > 0 {
+		return MissingScopeError{Missing: missing}
+	}
+	return nil
+}
+
+// SetGrantedScopes tells the client which permissions its token was
+// granted, typically from ParseScopes. It should be called before the
+// client is used.
+func (c *Client) SetGrantedScopes(p []Permission) {
+	c.scopes = append([]Permission(nil), p...)
+}
+
+// GrantedScopes returns the permissions set with SetGrantedScopes,
+// or nil if they are unknown
+func (c *Client) GrantedScopes() []Permission {
+	return c.scopes
+}
+
+// RequireScopes returns a MissingScopeError if the token of the client
+// lacks any of the required permissions. It always succeeds when the
+// granted permissions are unknown.
+func (c *Client) RequireScopes(required ...Permission) error {
+	if c.scopes == nil {
+		return nil
+	}
+	return RequireScopes(c.scopes, required...)
+}
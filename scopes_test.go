@@ -0,0 +1,119 @@
+package uphold
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestParseScopes(t *testing.T) {
+	tok := (&oauth2.Token{}).WithExtra(map[string]interface{}{
+		"scope": "cards:read cards:write,transactions:read",
+	})
+
+	want := []Permission{PermissionCardsRead, PermissionCardsWrite, PermissionTransactionsRead}
+	if got := ParseScopes(tok); !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseScopes() returned %v, want %v", got, want)
+	}
+
+	if got := ParseScopes(&oauth2.Token{}); got != nil {
+		t.Errorf("ParseScopes() without scope returned %v, want nil", got)
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	granted := []Permission{PermissionCardsRead, PermissionTransactionsRead}
+
+	if err := RequireScopes(granted, PermissionCardsRead); err != nil {
+		t.Errorf("RequireScopes() returned unexpected error: %v", err)
+	}
+
+	err := RequireScopes(granted, PermissionCardsRead, PermissionCardsWrite, PermissionTransactionsTransferOthers)
+	want := MissingScopeError{Missing: []Permission{PermissionCardsWrite, PermissionTransactionsTransferOthers}}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("RequireScopes() returned %#v, want %#v", err, want)
+	}
+	if got, want := err.Error(), "uphold: token lacks required scopes: cards:write, transactions:transfer:others"; got != want {
+		t.Errorf("Error() is %q, want %q", got, want)
+	}
+}
+
+func TestClientRequireScopes(t *testing.T) {
+	c := NewClient(nil)
+	if err := c.RequireScopes(PermissionCardsWrite); err != nil {
+		t.Errorf("RequireScopes() with unknown scopes returned %v, want nil", err)
+	}
+
+	c.SetGrantedScopes([]Permission{PermissionCardsRead})
+	if _, ok := c.RequireScopes(PermissionCardsWrite).(MissingScopeError); !ok {
+		t.Error("RequireScopes() should return a MissingScopeError")
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.SetPersonalAccessToken("user-token")
+
+	mux.HandleFunc("/revoke", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if id, secret, ok := r.BasicAuth(); !ok || id != "id" || secret != "secret" {
+			t.Errorf("revoke request basic auth is %v:%v, want id:secret", id, secret)
+		}
+		testFormValues(t, r, values{"token": "user-token"})
+	})
+
+	if _, err := client.RevokeToken(Credential{ClientID: "id", ClientSecret: "secret"}, "user-token"); err != nil {
+		t.Errorf("RevokeToken() returned unexpected error: %v", err)
+	}
+}
+
+// countingTransport counts the requests it sends
+type countingTransport struct {
+	n int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.n++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestRevokeTokenTransport(t *testing.T) {
+	setup()
+	defer teardown()
+
+	base := new(countingTransport)
+	client.http = &http.Client{Transport: &oauth2.Transport{
+		Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "user-token"}),
+		Base:   base,
+	}}
+
+	mux.HandleFunc("/revoke", func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); !ok {
+			t.Errorf("revoke request authorization is %q, want basic auth", r.Header.Get("Authorization"))
+		}
+	})
+
+	if _, err := client.RevokeToken(Credential{ClientID: "id", ClientSecret: "secret"}, "user-token"); err != nil {
+		t.Errorf("RevokeToken() returned unexpected error: %v", err)
+	}
+	if base.n != 1 {
+		t.Errorf("transport of the client sent %d requests, want 1", base.n)
+	}
+}
+
+func TestRevokeTokenContext(t *testing.T) {
+	setup()
+	defer teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.WithContext(ctx).RevokeToken(Credential{ClientID: "id"}, "user-token"); err == nil {
+		t.Error("RevokeToken() with a cancelled context should fail")
+	}
+}
@@ -9,7 +9,7 @@ type TickerService struct {
 
 // ListAll retrieces a list of tickers
 func (t *TickerService) ListAll() (*[]CurrencyPair, *Response, error) {
-	req, err := t.client.NewRequest("GET", "ticker", nil)
+	req, err := t.client.newRequest(OperationTickerListAll, "", "GET", "ticker", nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -27,7 +27,7 @@ func (t *TickerService) ListAll() (*[]CurrencyPair, *Response, error) {
 func (t *TickerService) List(cur CurrencyCode) (*[]CurrencyPair, *Response, error) {
 	rel := fmt.Sprintf("ticker/%s", cur)
 
-	req, err := t.client.NewRequest("GET", rel, nil)
+	req, err := t.client.newRequest(OperationTickerList, "", "GET", rel, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,229 @@
+package uphold
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNoToken is returned by a TokenStore which holds no token
+var ErrNoToken = errors.New("uphold: no token stored")
+
+// TokenStore persists OAuth tokens between runs
+type TokenStore interface {
+	// Load returns the stored token or ErrNoToken if there is none
+	Load() (*oauth2.Token, error)
+
+	// Save replaces the stored token
+	Save(t *oauth2.Token) error
+}
+
+// MemoryTokenStore keeps the token in memory
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// NewMemoryTokenStore returns a memory store holding t, which may be nil
+func NewMemoryTokenStore(t *oauth2.Token) *MemoryTokenStore {
+	return &MemoryTokenStore{token: t}
+}
+
+// Load returns the stored token
+func (m *MemoryTokenStore) Load() (*oauth2.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token == nil {
+		return nil, ErrNoToken
+	}
+	t := *m.token
+	return &t, nil
+}
+
+// Save replaces the stored token
+func (m *MemoryTokenStore) Save(t *oauth2.Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := *t
+	m.token = &c
+	return nil
+}
+
+// FileTokenStore keeps the token in a file encrypted
+// with AES-GCM. The file is only readable by its owner.
+type FileTokenStore struct {
+	path string
+	aead cipher.AEAD
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore returns a store saving the token to path. The
+// key must be 16, 24 or 32 bytes long to select AES-128, AES-192
+// or AES-256.
+func NewFileTokenStore(path string, key []byte) (*FileTokenStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileTokenStore{path: path, aead: aead}, nil
+}
+
+// Load decrypts and returns the stored token
+func (f *FileTokenStore) Load() (*oauth2.Token, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	n := f.aead.NonceSize()
+	if len(b) < n {
+		return nil, fmt.Errorf("uphold: token file %s is corrupt", f.path)
+	}
+
+	plain, err := f.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("uphold: cannot decrypt token file %s: %s", f.path, err)
+	}
+
+	t := new(oauth2.Token)
+	if err := json.Unmarshal(plain, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Save encrypts the token and atomically replaces the file
+func (f *FileTokenStore) Save(t *oauth2.Token) error {
+	plain, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, f.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return writeFileAtomic(f.path, f.aead.Seal(nonce, nonce, plain, nil), 0600)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and
+// renames it over path, so readers never observe a partial write
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// storedTokenSource refreshes tokens loaded from a TokenStore
+// and saves the refreshed ones back
+type storedTokenSource struct {
+	conf  *oauth2.Config
+	store TokenStore
+
+	mu    sync.Mutex
+	token *oauth2.Token
+
+	// unsaved is set while the token could not be saved
+	unsaved bool
+}
+
+// NewTokenSource returns a TokenSource which loads the token from store,
+// refreshes it with conf when it expires and saves the refreshed token,
+// including a rotated refresh token, back to the store. It is safe for
+// concurrent use and refreshes the token only once when several
+// goroutines find it expired. If the refreshed token cannot be saved it
+// is still used, and returned along with the error, so that a rotated
+// refresh token is never lost. Saving it is tried again on the next call.
+func NewTokenSource(conf *oauth2.Config, store TokenStore) oauth2.TokenSource {
+	return &storedTokenSource{conf: conf, store: store}
+}
+
+// Token returns a valid token, refreshing it if necessary
+func (s *storedTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		t, err := s.store.Load()
+		if err != nil {
+			return nil, err
+		}
+		s.token = t
+	}
+
+	if s.token.Valid() {
+		return s.token, s.save()
+	}
+
+	t, err := s.conf.TokenSource(oauth2.NoContext, s.token).Token()
+	if err != nil {
+		return nil, err
+	}
+	if t.RefreshToken == "" {
+		t.RefreshToken = s.token.RefreshToken
+	}
+
+	// the previous refresh token may be revoked already
+	s.token, s.unsaved = t, true
+	return t, s.save()
+}
+
+// save saves the token if it was not saved yet, s.mu must be held
+func (s *storedTokenSource) save() error {
+	if !s.unsaved {
+		return nil
+	}
+	if err := s.store.Save(s.token); err != nil {
+		return fmt.Errorf("uphold: cannot save the refreshed token: %s", err)
+	}
+	s.unsaved = false
+	return nil
+}
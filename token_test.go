@@ -0,0 +1,196 @@
+package uphold
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestMemoryTokenStore(t *testing.T) {
+	s := NewMemoryTokenStore(nil)
+	if _, err := s.Load(); err != ErrNoToken {
+		t.Errorf("Load() on empty store returned %v, want ErrNoToken", err)
+	}
+
+	s.Save(&oauth2.Token{AccessToken: "a"})
+	tok, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if got, want := tok.AccessToken, "a"; got != want {
+		t.Errorf("Load() returned token %v, want %v", got, want)
+	}
+}
+
+func TestFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "uphold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "token")
+	key := bytes.Repeat([]byte{1}, 32)
+
+	s, err := NewFileTokenStore(path, key)
+	if err != nil {
+		t.Fatalf("NewFileTokenStore() returned unexpected error: %v", err)
+	}
+	if _, err := s.Load(); err != ErrNoToken {
+		t.Errorf("Load() on missing file returned %v, want ErrNoToken", err)
+	}
+
+	want := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer"}
+	if err := s.Save(want); err != nil {
+		t.Fatalf("Save() returned unexpected error: %v", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != 0600 {
+		t.Errorf("token file mode is %v, want 0600", got)
+	}
+
+	b, _ := ioutil.ReadFile(path)
+	if bytes.Contains(b, []byte("refresh")) {
+		t.Error("token file contains the plain refresh token")
+	}
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() returned unexpected error: %v", err)
+	}
+	if got.AccessToken != want.AccessToken || got.RefreshToken != want.RefreshToken {
+		t.Errorf("Load() returned %+v, want %+v", got, want)
+	}
+
+	other, _ := NewFileTokenStore(path, bytes.Repeat([]byte{2}, 32))
+	if _, err := other.Load(); err == nil {
+		t.Error("Load() with the wrong key should fail")
+	}
+
+	if _, err := NewFileTokenStore(path, []byte("short")); err == nil {
+		t.Error("NewFileTokenStore() with an invalid key should fail")
+	}
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var refreshes int32
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		if got, want := r.FormValue("refresh_token"), "old-refresh"; got != want {
+			t.Errorf("refresh_token is %v, want %v", got, want)
+		}
+
+		n := atomic.AddInt32(&refreshes, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "access-%d", "refresh_token": "new-refresh", "expires_in": 3600}`, n)
+	})
+
+	conf := &oauth2.Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: server.URL + "/oauth2/token"},
+	}
+	store := NewMemoryTokenStore(&oauth2.Token{
+		AccessToken:  "expired",
+		RefreshToken: "old-refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	})
+
+	src := NewTokenSource(conf, store)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tok, err := src.Token()
+			if err != nil {
+				t.Errorf("Token() returned unexpected error: %v", err)
+				return
+			}
+			if got, want := tok.AccessToken, "access-1"; got != want {
+				t.Errorf("Token() returned %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&refreshes); got != 1 {
+		t.Errorf("token refreshed %d times, want 1", got)
+	}
+
+	saved, _ := store.Load()
+	if got, want := saved.RefreshToken, "new-refresh"; got != want {
+		t.Errorf("stored refresh token is %v, want %v", got, want)
+	}
+}
+
+// failingTokenStore fails to save while fail is set
+type failingTokenStore struct {
+	*MemoryTokenStore
+	fail bool
+}
+
+func (f *failingTokenStore) Save(t *oauth2.Token) error {
+	if f.fail {
+		return errors.New("disk full")
+	}
+	return f.MemoryTokenStore.Save(t)
+}
+
+func TestTokenSourceSaveError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var refreshes int32
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&refreshes, 1)
+		if got, want := r.FormValue("refresh_token"), "old-refresh"; got != want {
+			t.Errorf("refresh_token is %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token": "access", "refresh_token": "new-refresh", "expires_in": 3600}`)
+	})
+
+	conf := &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/oauth2/token"}}
+	store := &failingTokenStore{NewMemoryTokenStore(&oauth2.Token{
+		AccessToken:  "expired",
+		RefreshToken: "old-refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	}), true}
+
+	src := NewTokenSource(conf, store)
+	tok, err := src.Token()
+	if err == nil || tok == nil || tok.RefreshToken != "new-refresh" {
+		t.Fatalf("Token() returned %+v, %v, want the refreshed token and an error", tok, err)
+	}
+
+	// the rotated token is kept and saved once the store works again
+	store.fail = false
+	if tok, err = src.Token(); err != nil || tok.AccessToken != "access" {
+		t.Errorf("Token() returned %+v, %v, want the refreshed token", tok, err)
+	}
+	if got := atomic.LoadInt32(&refreshes); got != 1 {
+		t.Errorf("token refreshed %d times, want 1", got)
+	}
+	if saved, _ := store.Load(); saved.RefreshToken != "new-refresh" {
+		t.Errorf("stored refresh token is %v, want new-refresh", saved.RefreshToken)
+	}
+}
@@ -0,0 +1,103 @@
+package uphold
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Tracer starts spans, as implemented by a thin adapter over an
+// OpenTelemetry trace.Tracer
+type Tracer interface {
+	// Start starts a span named name as a child of the span in
+	// ctx, returning a context carrying the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced API request
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// HeaderInjector is implemented by Tracers which propagate the span
+// in ctx to the API by adding headers to the request, such as the
+// traceparent header of W3C trace context
+type HeaderInjector interface {
+	Inject(ctx context.Context, h http.Header)
+}
+
+// Attributes set by TracingMiddleware
+const (
+	AttributeOperation     = "uphold.operation"
+	AttributeCardID        = "uphold.card_id"
+	AttributeTransactionID = "uphold.transaction_id"
+	AttributeAccountID     = "uphold.account_id"
+	AttributeHTTPMethod    = "http.method"
+	AttributeHTTPPath      = "http.path"
+	AttributeHTTPStatus    = "http.status_code"
+)
+
+// TracingMiddleware creates a span for every request, named by operation
+// such as "Transaction.Commit" and child of the span in the context of the
+// request, see Client.WithContext. The identifiers of the card, transaction
+// or account are attached as attributes and errors are recorded, including
+// the API errors returned by CheckResponse.
+func TracingMiddleware(t Tracer) Middleware {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request, v interface{}) (*Response, error) {
+			name := "uphold " + req.Method
+			op := RequestOperation(req)
+			if op != "" {
+				name = op.String()
+			}
+
+			ctx, span := t.Start(req.Context(), name)
+			defer span.End()
+
+			if op != "" {
+				span.SetAttribute(AttributeOperation, op.String())
+			}
+			span.SetAttribute(AttributeHTTPMethod, req.Method)
+			span.SetAttribute(AttributeHTTPPath, NormalizeEndpoint(req.URL.Path))
+			for k, id := range pathIDs(req.URL.Path) {
+				span.SetAttribute(k, id)
+			}
+
+			req = req.WithContext(ctx)
+			if inj, ok := t.(HeaderInjector); ok {
+				inj.Inject(ctx, req.Header)
+			}
+
+			resp, err := next.Do(req, v)
+			if resp != nil {
+				span.SetAttribute(AttributeHTTPStatus, resp.StatusCode)
+			}
+			if err != nil {
+				span.RecordError(err)
+			}
+			return resp, err
+		})
+	}
+}
+
+// Attributes of the identifiers following each collection in a path
+var idAttributes = map[string]string{
+	"accounts":     AttributeAccountID,
+	"cards":        AttributeCardID,
+	"transactions": AttributeTransactionID,
+}
+
+// pathIDs returns the identifiers found in an API path by attribute
+func pathIDs(path string) map[string]string {
+	ids := map[string]string{}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		if k, ok := idAttributes[segments[i-1]]; ok {
+			ids[k] = segments[i]
+		}
+	}
+	return ids
+}
@@ -0,0 +1,98 @@
+package uphold
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// testSpan records what is set on it
+type testSpan struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)                      { s.err = err }
+func (s *testSpan) End()                                       { s.ended = true }
+
+type spanKey struct{}
+
+// testTracer records the started spans and injects their name
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	s := &testSpan{name: name, attrs: map[string]interface{}{}}
+	if p, ok := ctx.Value(spanKey{}).(*testSpan); ok {
+		s.parent = p.name
+	}
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (t *testTracer) Inject(ctx context.Context, h http.Header) {
+	h.Set("X-Span", ctx.Value(spanKey{}).(*testSpan).name)
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/123/transactions/456/commit", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "X-Span", "Transaction.Commit")
+		w.Write([]byte(`{"id":"456"}`))
+	})
+
+	tr := new(testTracer)
+	client.Use(TracingMiddleware(tr))
+
+	ctx := context.WithValue(context.Background(), spanKey{}, &testSpan{name: "handler"})
+	c := client.WithContext(ctx)
+	if _, _, err := c.Transaction.Commit(Card{ID: "123"}, Txn{ID: "456"}, ""); err != nil {
+		t.Fatalf("Transaction.Commit() returned unexpected error: %v", err)
+	}
+
+	if len(tr.spans) != 1 {
+		t.Fatalf("started %d spans, want 1", len(tr.spans))
+	}
+	s := tr.spans[0]
+	if s.name != "Transaction.Commit" || s.parent != "handler" || !s.ended {
+		t.Errorf("span is %q child of %q, ended %v, want ended Transaction.Commit child of handler", s.name, s.parent, s.ended)
+	}
+
+	want := map[string]interface{}{
+		AttributeOperation:     "Transaction.Commit",
+		AttributeCardID:        "123",
+		AttributeTransactionID: "456",
+		AttributeHTTPMethod:    "POST",
+		AttributeHTTPPath:      "me/cards/{id}/transactions/{id}/commit",
+		AttributeHTTPStatus:    200,
+	}
+	for k, v := range want {
+		if s.attrs[k] != v {
+			t.Errorf("attribute %s is %v, want %v", k, s.attrs[k], v)
+		}
+	}
+}
+
+func TestTracingMiddlewareError(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+
+	tr := new(testTracer)
+	client.Use(TracingMiddleware(tr))
+	client.Card.ListAll()
+
+	if _, ok := tr.spans[0].err.(ErrorResponse); !ok {
+		t.Errorf("span recorded error %#v, want ErrorResponse", tr.spans[0].err)
+	}
+}
@@ -9,12 +9,49 @@ type TransactionService struct {
 
 // Create a new transaction on provided quote
 func (t *TransactionService) Create(card Card, q Quote) (*Txn, *Response, error) {
+	return t.createAs(card, q, transferOperation(q.Destination))
+}
+
+// CreateForApplication creates a transaction from a card of the
+// application owner, sent with the application token set with
+// SetApplicationCredentials. Committing or cancelling the pending
+// transaction uses the application token too.
+func (t *TransactionService) CreateForApplication(card Card, q Quote) (*Txn, *Response, error) {
+	return t.createAs(card, q, OperationTransferApplication)
+}
+
+// createAs creates the transaction, checking the permissions of kind
+// of transfer, one of OperationTransferSelf, OperationTransferOthers,
+// OperationWithdraw, OperationDeposit or OperationTransferApplication
+func (t *TransactionService) createAs(card Card, q Quote, kind Operation) (*Txn, *Response, error) {
+	r, resp, err := t.create(card, q, kind)
+	return t.client.audit(AuditRecord{
+		Operation:   OperationTransactionCreate,
+		Card:        card.ID,
+		Quote:       &q,
+		Destination: q.Destination,
+	}, r, resp, err)
+}
+
+// create sends the quote. With a policy, a realtime quote is
+// created without being committed, checked and then committed.
+func (t *TransactionService) create(card Card, q Quote, kind Operation) (*Txn, *Response, error) {
+	p := t.client.policy
+
 	rel := fmt.Sprintf("me/cards/%s/transactions", card.ID)
-	if q.Realtime {
+	commit := q.Realtime && !t.client.dryRun && p == nil
+	if commit {
 		rel = rel + "?commit=true"
 	}
 
-	req, err := t.client.NewRequest("POST", rel, q)
+	pr := quoteRequest(card, q, kind)
+	if p != nil {
+		if err := p.checkQuote(t.client, pr); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	req, err := t.client.newRequest(OperationTransactionCreate, kind, "POST", rel, q)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -25,33 +62,125 @@ func (t *TransactionService) Create(card Card, q Quote) (*Txn, *Response, error)
 		return nil, resp, err
 	}
 
+	if p != nil {
+		if err := p.quoted(t.client, pr, quotedRequest(pr, *txn)); err != nil {
+			return nil, resp, err
+		}
+	}
+	if kind == OperationTransferApplication && !commit {
+		t.client.trackApplicationTxn(txn.ID, false)
+	}
+
+	if q.Realtime && t.client.dryRun {
+		// only quoted in dry-run mode
+		txn.Status = TxnStatusCompleted
+		txn.Simulated = true
+	} else if q.Realtime && !commit {
+		return t.commitAs(card, *txn, "", kind)
+	}
+
 	return txn, resp, nil
 }
 
+// Withdraw funds of card to an address on a blockchain. The address and
+// the memo are validated before the quote is created, an AddressError is
+// returned if they are not valid.
+func (t *TransactionService) Withdraw(card Card, w Withdrawal) (*Txn, *Response, error) {
+	if err := w.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return t.Create(card, w.quote(card))
+}
+
 // Commit a pending transaction on card
 func (t *TransactionService) Commit(card Card, txn Txn, msg string) (*Txn, *Response, error) {
+	r, resp, err := t.commit(card, txn, msg)
+	return t.client.audit(AuditRecord{
+		Operation:   OperationTransactionCommit,
+		Card:        card.ID,
+		Transaction: txn.ID,
+		Destination: txnDestination(txn),
+	}, r, resp, err)
+}
+
+// commit sends the commit
+func (t *TransactionService) commit(card Card, txn Txn, msg string) (*Txn, *Response, error) {
+	return t.commitAs(card, txn, msg, t.client.applicationTxnOperation(txn, txnOperation(txn)))
+}
+
+// commitAs sends the commit, checking the permissions of scopeOp
+func (t *TransactionService) commitAs(card Card, txn Txn, msg string, scopeOp Operation) (*Txn, *Response, error) {
 	rel := fmt.Sprintf("me/cards/%s/transactions/%s/commit", card.ID, txn.ID)
 
+	// settle releases what the policy reserved for the
+	// transaction unless it was, or may have been, committed
+	settle := func(committed bool) {}
+	if p := t.client.policy; p != nil {
+		var err error
+		if settle, err = p.checkCommit(t.client, txn.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	payload := map[string]string{"message": msg}
 
-	req, err := t.client.NewRequest("POST", rel, payload)
+	req, err := t.client.newRequest(OperationTransactionCommit, scopeOp, "POST", rel, payload)
 	if err != nil {
+		settle(false)
 		return nil, nil, err
 	}
 
 	r := new(Txn)
 	resp, err := t.client.Do(req, r)
 	if err != nil {
+		settle(!commitRefused(err))
 		return nil, resp, err
 	}
 
+	if RequestSimulated(req) {
+		settle(false)
+		*r = txn
+		r.Message = msg
+		r.Status = TxnStatusCompleted
+		r.Simulated = true
+	} else {
+		settle(true)
+		t.client.trackApplicationTxn(txn.ID, true)
+	}
+
 	return r, resp, nil
 }
 
+// commitRefused reports whether a failed commit was certainly not made:
+// refused before being sent, or by the API with a client error. Server
+// errors and network failures leave the outcome unknown.
+func commitRefused(err error) bool {
+	switch e := err.(type) {
+	case PolicyViolationError, MissingScopeError, RateLimitError:
+		return true
+	case ErrorResponse:
+		c := e.Response.StatusCode
+		return c >= 400 && c < 500
+	}
+	return false
+}
+
 // Cancel an unclaimed transaction on a card
 func (t *TransactionService) Cancel(card Card, txn Txn) (*Txn, *Response, error) {
+	r, resp, err := t.cancel(card, txn)
+	return t.client.audit(AuditRecord{
+		Operation:   OperationTransactionCancel,
+		Card:        card.ID,
+		Transaction: txn.ID,
+		Destination: txnDestination(txn),
+	}, r, resp, err)
+}
+
+// cancel sends the cancellation
+func (t *TransactionService) cancel(card Card, txn Txn) (*Txn, *Response, error) {
 	rel := fmt.Sprintf("me/cards/%s/transactions/%s/cancel", card.ID, txn.ID)
-	req, err := t.client.NewRequest("POST", rel, nil)
+	scopeOp := t.client.applicationTxnOperation(txn, "")
+	req, err := t.client.newRequest(OperationTransactionCancel, scopeOp, "POST", rel, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -62,13 +191,32 @@ func (t *TransactionService) Cancel(card Card, txn Txn) (*Txn, *Response, error)
 		return nil, resp, err
 	}
 
+	if RequestSimulated(req) {
+		*r = txn
+		r.Status = TxnStatusCancelled
+		r.Simulated = true
+	} else {
+		t.client.trackApplicationTxn(txn.ID, true)
+	}
+
 	return r, resp, nil
 }
 
 // Resend a reminder on an unclaimed transaction
 func (t *TransactionService) Resend(card Card, txn Txn) (*Txn, *Response, error) {
+	r, resp, err := t.resend(card, txn)
+	return t.client.audit(AuditRecord{
+		Operation:   OperationTransactionResend,
+		Card:        card.ID,
+		Transaction: txn.ID,
+		Destination: txnDestination(txn),
+	}, r, resp, err)
+}
+
+// resend sends the reminder
+func (t *TransactionService) resend(card Card, txn Txn) (*Txn, *Response, error) {
 	rel := fmt.Sprintf("me/cards/%s/transactions/%s/resend", card.ID, txn.ID)
-	req, err := t.client.NewRequest("POST", rel, nil)
+	req, err := t.client.newRequest(OperationTransactionResend, "", "POST", rel, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -84,7 +232,7 @@ func (t *TransactionService) Resend(card Card, txn Txn) (*Txn, *Response, error)
 
 // ListForUser lists all the transactions for current user
 func (t *TransactionService) ListForUser() (*[]Txn, *Response, error) {
-	req, err := t.client.NewRequest("GET", "me/transactions", nil)
+	req, err := t.client.newRequest(OperationTransactionListForUser, "", "GET", "me/transactions", nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -101,7 +249,7 @@ func (t *TransactionService) ListForUser() (*[]Txn, *Response, error) {
 // ListForCard lists all the transactions for a card
 func (t *TransactionService) ListForCard(card Card) (*[]Txn, *Response, error) {
 	rel := fmt.Sprintf("me/cards/%s/transactions", card.ID)
-	req, err := t.client.NewRequest("GET", rel, nil)
+	req, err := t.client.newRequest(OperationTransactionListForCard, "", "GET", rel, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,582 @@
+package upholdtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gufran/uphold"
+)
+
+// apiError is the error body returned by the fake API
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// serveHTTP applies rate limits and injected faults
+// before routing the request to its handler
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, APIPrefix) {
+		writeError(w, http.StatusNotFound, "not_found", "Not Found")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, APIPrefix), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.allow(w) {
+		return
+	}
+
+	for i, f := range s.faults {
+		if (f.method == "" || f.method == r.Method) && f.path == path {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			writeError(w, f.status, "injected_fault", http.StatusText(f.status))
+			return
+		}
+	}
+
+	s.route(w, r, strings.Split(path, "/"))
+}
+
+// allow accounts the request against the rate limit and sets the rate
+// limit headers. It returns false if the request was rejected.
+func (s *Server) allow(w http.ResponseWriter) bool {
+	if s.rateLimit == 0 {
+		return true
+	}
+
+	now := time.Now()
+	if !now.Before(s.rateResetOn) {
+		s.rateRemain = s.rateLimit
+		s.rateResetOn = now.Add(s.rateWindow)
+	}
+
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(s.rateResetOn.Unix(), 10))
+
+	if s.rateRemain == 0 {
+		retry := int(s.rateResetOn.Sub(now)/time.Second) + 1
+		h.Set("X-RateLimit-Remaining", "0")
+		h.Set("Retry-After", strconv.Itoa(retry))
+		writeError(w, http.StatusTooManyRequests, "too_many_requests", "Rate limit exceeded")
+		return false
+	}
+
+	s.rateRemain--
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(s.rateRemain))
+	return true
+}
+
+// route dispatches the request based on the segments of its path
+func (s *Server) route(w http.ResponseWriter, r *http.Request, seg []string) {
+	n := len(seg)
+
+	switch {
+	case n >= 1 && seg[0] == "ticker":
+		s.handleTicker(w, r, seg[1:])
+	case n >= 2 && seg[0] == "me" && seg[1] == "cards":
+		s.handleCards(w, r, seg[2:])
+	case n >= 2 && seg[0] == "me" && seg[1] == "contacts":
+		s.handleContacts(w, r, seg[2:])
+	case n >= 2 && seg[0] == "me" && seg[1] == "accounts":
+		s.handleAccounts(w, r, seg[2:])
+	case n == 2 && seg[0] == "me" && seg[1] == "transactions" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.listTransactions(""))
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Not Found")
+	}
+}
+
+func (s *Server) handleTicker(w http.ResponseWriter, r *http.Request, seg []string) {
+	if r.Method != "GET" || len(seg) > 1 {
+		writeError(w, http.StatusNotFound, "not_found", "Not Found")
+		return
+	}
+
+	pairs := []uphold.CurrencyPair{}
+	for _, p := range s.tickers {
+		if len(seg) == 0 || strings.Contains(p.Pair, seg[0]) {
+			pairs = append(pairs, p)
+		}
+	}
+	writeJSON(w, http.StatusOK, pairs)
+}
+
+func (s *Server) handleCards(w http.ResponseWriter, r *http.Request, seg []string) {
+	switch {
+	case len(seg) == 0 && r.Method == "GET":
+		cards := make([]uphold.Card, 0, len(s.cardOrder))
+		for _, id := range s.cardOrder {
+			cards = append(cards, *s.cards[id])
+		}
+		writeJSON(w, http.StatusOK, cards)
+
+	case len(seg) == 0 && r.Method == "POST":
+		var c uphold.Card
+		if !readJSON(w, r, &c) {
+			return
+		}
+		if c.Currency == "" || c.Label == "" {
+			writeError(w, http.StatusBadRequest, "validation_failed", "label and currency are required")
+			return
+		}
+
+		card := &uphold.Card{
+			ID:       s.nextID(),
+			Label:    c.Label,
+			Currency: c.Currency,
+			Settings: &uphold.CardSettings{Position: len(s.cardOrder) + 1},
+		}
+		s.cards[card.ID] = card
+		s.cardOrder = append(s.cardOrder, card.ID)
+		writeJSON(w, http.StatusOK, card)
+
+	case len(seg) == 1 && r.Method == "GET":
+		c, ok := s.cards[seg[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "Card not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+
+	case len(seg) == 1 && r.Method == "PATCH":
+		s.updateCard(w, r, seg[0])
+
+	case len(seg) == 1 && r.Method == "DELETE":
+		s.deleteCard(w, seg[0])
+
+	case len(seg) >= 2 && seg[1] == "transactions":
+		if _, ok := s.cards[seg[0]]; !ok {
+			writeError(w, http.StatusNotFound, "not_found", "Card not found")
+			return
+		}
+		s.handleTransactions(w, r, seg[0], seg[2:])
+
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Not Found")
+	}
+}
+
+// deleteCard removes an empty card
+func (s *Server) deleteCard(w http.ResponseWriter, ID string) {
+	c, ok := s.cards[ID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "Card not found")
+		return
+	}
+	if c.Balance != 0 {
+		writeError(w, http.StatusBadRequest, "card_not_empty", "Card must be empty to be deleted")
+		return
+	}
+
+	delete(s.cards, ID)
+	for i, id := range s.cardOrder {
+		if id == ID {
+			s.cardOrder = append(s.cardOrder[:i], s.cardOrder[i+1:]...)
+			break
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateCard applies the fields present in the request body
+// to the card, leaving the absent ones untouched
+func (s *Server) updateCard(w http.ResponseWriter, r *http.Request, ID string) {
+	c, ok := s.cards[ID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "not_found", "Card not found")
+		return
+	}
+
+	var p struct {
+		Label    *string `json:"label"`
+		Settings *struct {
+			Position *int  `json:"position"`
+			Starred  *bool `json:"starred"`
+		} `json:"settings"`
+	}
+	if !readJSON(w, r, &p) {
+		return
+	}
+
+	if p.Label != nil {
+		c.Label = *p.Label
+	}
+	if p.Settings != nil {
+		if c.Settings == nil {
+			c.Settings = &uphold.CardSettings{}
+		}
+		if p.Settings.Position != nil {
+			c.Settings.Position = *p.Settings.Position
+		}
+		if p.Settings.Starred != nil {
+			c.Settings.Starred = *p.Settings.Starred
+		}
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) handleContacts(w http.ResponseWriter, r *http.Request, seg []string) {
+	switch {
+	case len(seg) == 0 && r.Method == "GET":
+		contacts := make([]uphold.Contact, 0, len(s.contactOrder))
+		for _, id := range s.contactOrder {
+			contacts = append(contacts, *s.contacts[id])
+		}
+		writeJSON(w, http.StatusOK, contacts)
+
+	case len(seg) == 0 && r.Method == "POST":
+		var c uphold.Contact
+		if !readJSON(w, r, &c) {
+			return
+		}
+		if c.FirstName == "" && c.LastName == "" && c.Company == "" {
+			writeError(w, http.StatusBadRequest, "validation_failed", "a name or company is required")
+			return
+		}
+
+		c.ID = s.nextID()
+		c.Name = strings.TrimSpace(c.FirstName + " " + c.LastName)
+		s.contacts[c.ID] = &c
+		s.contactOrder = append(s.contactOrder, c.ID)
+		writeJSON(w, http.StatusOK, c)
+
+	case len(seg) == 1 && r.Method == "GET":
+		c, ok := s.contacts[seg[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "Contact not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, c)
+
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Not Found")
+	}
+}
+
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request, seg []string) {
+	switch {
+	case len(seg) == 0 && r.Method == "GET":
+		accounts := make([]uphold.Account, 0, len(s.accountOrder))
+		for _, id := range s.accountOrder {
+			accounts = append(accounts, *s.accounts[id])
+		}
+		writeJSON(w, http.StatusOK, accounts)
+
+	case len(seg) == 1 && r.Method == "GET":
+		a, ok := s.accounts[seg[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not_found", "Account not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, a)
+
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Not Found")
+	}
+}
+
+func (s *Server) handleTransactions(w http.ResponseWriter, r *http.Request, cardID string, seg []string) {
+	switch {
+	case len(seg) == 0 && r.Method == "GET":
+		writeJSON(w, http.StatusOK, s.listTransactions(cardID))
+
+	case len(seg) == 0 && r.Method == "POST":
+		var q uphold.Quote
+		if !readJSON(w, r, &q) {
+			return
+		}
+		s.createTransaction(w, cardID, q, r.URL.Query().Get("commit") == "true")
+
+	case len(seg) == 2 && r.Method == "POST":
+		t, ok := s.txns[seg[0]]
+		if !ok || t.cardID != cardID {
+			writeError(w, http.StatusNotFound, "not_found", "Transaction not found")
+			return
+		}
+
+		switch seg[1] {
+		case "commit":
+			var body struct {
+				Message string `json:"message"`
+			}
+			if !readJSON(w, r, &body) {
+				return
+			}
+			t.txn.Message = body.Message
+			s.commitTransaction(w, t)
+		case "cancel":
+			s.cancelTransaction(w, t)
+		case "resend":
+			if t.txn.Status != uphold.TxnStatusWaiting {
+				writeError(w, http.StatusConflict, "transaction_not_waiting", "Only unclaimed transactions can be resent")
+				return
+			}
+			writeJSON(w, http.StatusOK, t.txn)
+		default:
+			writeError(w, http.StatusNotFound, "not_found", "Not Found")
+		}
+
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "Not Found")
+	}
+}
+
+// listTransactions returns the transactions touching the given
+// card, or all of them if cardID is empty
+func (s *Server) listTransactions(cardID string) []uphold.Txn {
+	txns := []uphold.Txn{}
+	for _, id := range s.txnOrder {
+		t := s.txns[id]
+		if cardID == "" || t.cardID == cardID || t.txn.Destination.CardID == cardID {
+			txns = append(txns, t.txn)
+		}
+	}
+	return txns
+}
+
+// createTransaction creates a quote on the card and optionally commits it
+func (s *Server) createTransaction(w http.ResponseWriter, cardID string, q uphold.Quote, commit bool) {
+	if q.Denomination == nil || q.Denomination.Amount <= 0 || q.Denomination.Currency == "" {
+		writeError(w, http.StatusBadRequest, "validation_failed", "denomination is required")
+		return
+	}
+	if q.Origin != "" {
+		s.createDeposit(w, cardID, q, commit)
+		return
+	}
+	if q.Destination == "" {
+		writeError(w, http.StatusBadRequest, "validation_failed", "destination is required")
+		return
+	}
+
+	card := s.cards[cardID]
+	amount := q.Denomination.Amount
+	currency := q.Denomination.Currency.String()
+
+	rate, ok := s.paidRate(currency, card.Currency)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unsupported_pair", "Unsupported currency pair")
+		return
+	}
+
+	txn := uphold.Txn{
+		ID:     s.nextID(),
+		Type:   uphold.TxnTypeTransfer,
+		Status: uphold.TxnStatusPending,
+		Denomination: &uphold.Denomination{
+			Amount:   amount,
+			Currency: currency,
+			Pair:     currency + card.Currency,
+			Rate:     rate,
+		},
+		Origin: uphold.Origin{
+			CardID:   cardID,
+			Amount:   amount * rate,
+			Currency: card.Currency,
+			Rate:     rate,
+			Type:     uphold.OriginTypeCard,
+		},
+	}
+
+	now := time.Now().UTC()
+	txn.CreatedAt = &now
+
+	if txn.Origin.Amount > card.Available {
+		writeError(w, http.StatusBadRequest, "insufficient_balance", "Not enough funds for the transaction")
+		return
+	}
+
+	if dest, ok := s.cards[q.Destination]; ok {
+		destRate, ok := s.receivedRate(currency, dest.Currency)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "unsupported_pair", "Unsupported currency pair")
+			return
+		}
+		txn.Destination = uphold.Destination{
+			CardID:   dest.ID,
+			Amount:   amount * destRate,
+			Currency: dest.Currency,
+			Rate:     destRate,
+			Type:     uphold.DestinationTypeCard,
+		}
+	} else {
+		txn.Destination = uphold.Destination{
+			Amount:      amount,
+			Currency:    currency,
+			Description: q.Destination,
+			Type:        uphold.DestinationTypeEmail,
+		}
+		if a, ok := s.accounts[q.Destination]; ok && bankAccount(a) {
+			txn.Type = uphold.TxnTypeWithdrawal
+			txn.Destination.Description = a.Label
+			txn.Destination.Type = uphold.DestinationType(a.Type)
+		} else if !strings.Contains(q.Destination, "@") {
+			txn.Type = uphold.TxnTypeWithdrawal
+			txn.Destination.Type = uphold.DestinationTypeExternal
+		}
+	}
+
+	t := &transaction{cardID: cardID, txn: txn}
+	s.txns[txn.ID] = t
+	s.txnOrder = append(s.txnOrder, txn.ID)
+
+	if commit {
+		s.commitTransaction(w, t)
+		return
+	}
+	writeJSON(w, http.StatusOK, t.txn)
+}
+
+// bankAccount reports whether the account is a
+// bank account funds can be deposited from
+func bankAccount(a *uphold.Account) bool {
+	return a.Type == uphold.AccountTypeSepa || a.Type == uphold.AccountACH
+}
+
+// createDeposit creates a deposit from the bank account
+// of the quote to the card and optionally commits it
+func (s *Server) createDeposit(w http.ResponseWriter, cardID string, q uphold.Quote, commit bool) {
+	a, ok := s.accounts[q.Origin]
+	if !ok || !bankAccount(a) {
+		writeError(w, http.StatusBadRequest, "validation_failed", "origin is not a bank account")
+		return
+	}
+
+	card := s.cards[cardID]
+	amount := q.Denomination.Amount
+	currency := q.Denomination.Currency.String()
+
+	rate, ok := s.receivedRate(currency, card.Currency)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "unsupported_pair", "Unsupported currency pair")
+		return
+	}
+
+	now := time.Now().UTC()
+	txn := uphold.Txn{
+		ID:        s.nextID(),
+		Type:      uphold.TxnTypeDeposit,
+		Status:    uphold.TxnStatusPending,
+		CreatedAt: &now,
+		Denomination: &uphold.Denomination{
+			Amount:   amount,
+			Currency: currency,
+			Pair:     currency + card.Currency,
+			Rate:     rate,
+		},
+		Origin: uphold.Origin{
+			Amount:      amount,
+			Currency:    currency,
+			Description: a.Label,
+			Type:        uphold.OriginType(a.Type),
+		},
+		Destination: uphold.Destination{
+			CardID:   cardID,
+			Amount:   amount * rate,
+			Currency: card.Currency,
+			Rate:     rate,
+			Type:     uphold.DestinationTypeCard,
+		},
+	}
+
+	t := &transaction{cardID: cardID, txn: txn}
+	s.txns[txn.ID] = t
+	s.txnOrder = append(s.txnOrder, txn.ID)
+
+	if commit {
+		s.commitTransaction(w, t)
+		return
+	}
+	writeJSON(w, http.StatusOK, t.txn)
+}
+
+// commitTransaction moves the funds of a pending transaction. Funds sent
+// to an email address stay unclaimed until the transaction is cancelled.
+// Deposits only credit the card.
+func (s *Server) commitTransaction(w http.ResponseWriter, t *transaction) {
+	if t.txn.Status != uphold.TxnStatusPending {
+		writeError(w, http.StatusConflict, "transaction_not_pending", "Only pending transactions can be committed")
+		return
+	}
+
+	if t.txn.Type == uphold.TxnTypeDeposit {
+		card := s.cards[t.cardID]
+		card.Balance += t.txn.Destination.Amount
+		card.Available += t.txn.Destination.Amount
+		t.txn.Status = uphold.TxnStatusCompleted
+		writeJSON(w, http.StatusOK, t.txn)
+		return
+	}
+
+	origin := s.cards[t.cardID]
+	if t.txn.Origin.Amount > origin.Available {
+		writeError(w, http.StatusBadRequest, "insufficient_balance", "Not enough funds for the transaction")
+		return
+	}
+
+	origin.Balance -= t.txn.Origin.Amount
+	origin.Available -= t.txn.Origin.Amount
+
+	switch t.txn.Destination.Type {
+	case uphold.DestinationTypeCard:
+		if dest, ok := s.cards[t.txn.Destination.CardID]; ok {
+			dest.Balance += t.txn.Destination.Amount
+			dest.Available += t.txn.Destination.Amount
+		}
+		t.txn.Status = uphold.TxnStatusCompleted
+	case uphold.DestinationTypeEmail:
+		t.txn.Status = uphold.TxnStatusWaiting
+	default:
+		t.txn.Status = uphold.TxnStatusCompleted
+	}
+
+	now := time.Now().UTC()
+	origin.LastTransactionAt = &now
+
+	writeJSON(w, http.StatusOK, t.txn)
+}
+
+// cancelTransaction cancels a pending quote or an unclaimed
+// transaction, refunding the origin card in the latter case
+func (s *Server) cancelTransaction(w http.ResponseWriter, t *transaction) {
+	switch t.txn.Status {
+	case uphold.TxnStatusPending:
+	case uphold.TxnStatusWaiting:
+		origin := s.cards[t.cardID]
+		origin.Balance += t.txn.Origin.Amount
+		origin.Available += t.txn.Origin.Amount
+	default:
+		writeError(w, http.StatusConflict, "transaction_not_cancellable", "Transaction can no longer be cancelled")
+		return
+	}
+
+	t.txn.Status = uphold.TxnStatusCancelled
+	writeJSON(w, http.StatusOK, t.txn)
+}
+
+// readJSON decodes the request body into v and writes
+// an error response if the body is malformed
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_body", err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, apiError{Code: code, Message: msg})
+}
@@ -0,0 +1,272 @@
+package upholdtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/gufran/uphold/internal/redact"
+)
+
+// Mode is the operating mode of a Recorder
+type Mode int
+
+// Available recorder modes
+const (
+	// ModeReplay serves responses from the cassette
+	// and never touches the network
+	ModeReplay Mode = iota
+
+	// ModeRecord sends requests through the real transport
+	// and saves the interactions to the cassette
+	ModeRecord
+)
+
+// RecordEnv is the environment variable which switches
+// ModeFromEnv to record mode when set to a non-empty value
+const RecordEnv = "UPHOLD_RECORD"
+
+// ModeFromEnv returns ModeRecord if RecordEnv
+// is set and ModeReplay otherwise
+func ModeFromEnv() Mode {
+	if os.Getenv(RecordEnv) != "" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Redacted replaces the scrubbed values in a cassette
+const Redacted = redact.Redacted
+
+// redactedEmail replaces email addresses so
+// the scrubbed value remains a valid address
+const redactedEmail = "redacted@example.com"
+
+// Cassette is the set of interactions saved to a fixture file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and response pair
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the scrubbed form of a request
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the scrubbed form of a response
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper which records interactions with the
+// API to a cassette file, or replays them from it
+type Recorder struct {
+	// Transport performs the real requests in record mode.
+	// http.DefaultTransport is used if nil.
+	Transport http.RoundTripper
+
+	// MatchBody requires the scrubbed request bodies
+	// to be equal when matching recorded requests
+	MatchBody bool
+
+	// Scrub, if set, is called on every interaction after the
+	// default scrubbing and before it is saved to the cassette
+	Scrub func(*Interaction)
+
+	mode Mode
+	path string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder returns a Recorder backed by the cassette at path. In replay
+// mode the cassette must exist and is loaded immediately.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{mode: mode, path: path}
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &r.cassette); err != nil {
+		return nil, fmt.Errorf("upholdtest: invalid cassette %s: %s", path, err)
+	}
+
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// Mode returns the operating mode of the recorder
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Client returns an http.Client which uses the recorder as its transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+	return r.replay(req, body)
+}
+
+// Stop saves the recorded interactions to the cassette.
+// It does nothing in replay mode.
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, append(b, '\n'), 0644)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	i := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: scrubHeader(req.Header),
+			Body:   scrubBody(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     scrubHeader(resp.Header),
+			Body:       scrubBody(respBody),
+		},
+	}
+	if r.Scrub != nil {
+		r.Scrub(&i)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// replay serves the first unused interaction matching the request
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	scrubbed := scrubBody(body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for n, i := range r.cassette.Interactions {
+		if r.used[n] || i.Request.Method != req.Method || !sameRequestURL(i.Request.URL, req.URL) {
+			continue
+		}
+		if r.MatchBody && i.Request.Body != scrubbed {
+			continue
+		}
+
+		r.used[n] = true
+
+		header := http.Header{}
+		for k, v := range i.Response.Header {
+			header[k] = v
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+			StatusCode:    i.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewBufferString(i.Response.Body)),
+			ContentLength: int64(len(i.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("upholdtest: no recorded interaction for %s %s in %s", req.Method, req.URL, r.path)
+}
+
+// sameRequestURL reports whether the recorded URL has the path and the
+// query of u. The host is ignored so that cassettes recorded against the
+// sandbox replay against any server.
+func sameRequestURL(recorded string, u *url.URL) bool {
+	r, err := url.Parse(recorded)
+	if err != nil {
+		return false
+	}
+	return r.Path == u.Path && reflect.DeepEqual(r.Query(), u.Query())
+}
+
+// scrubHeader returns a copy of h with secret headers redacted
+func scrubHeader(h http.Header) http.Header {
+	return redact.Header(h)
+}
+
+// scrubBody redacts secrets and personal data from a JSON body
+func scrubBody(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return redact.Body(b, redactedEmail)
+}
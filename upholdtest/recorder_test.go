@@ -0,0 +1,165 @@
+package upholdtest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gufran/uphold"
+)
+
+func TestRecorderRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upholdtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cards.json")
+
+	s := NewServer()
+	s.AddCard(uphold.Card{
+		Label:    "USD",
+		Currency: "USD",
+		Address:  map[string]string{"bitcoin": "1BoatSLRHtKNngkdXEeobR76b53LETtpyT"},
+	})
+	s.AddContact(uphold.Contact{FirstName: "Jane", LastName: "Doe", Emails: []string{"jane@example.org"}})
+
+	rec, err := NewRecorder(path, ModeRecord)
+	if err != nil {
+		t.Fatalf("NewRecorder() returned unexpected error: %v", err)
+	}
+
+	client := uphold.NewClient(rec.Client())
+	client.SetAPIURL(s.URL + APIPrefix)
+
+	req, _ := client.NewRequest("GET", "me/cards", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	recorded := new([]uphold.Card)
+	if _, err := client.Do(req, recorded); err != nil {
+		t.Fatalf("recording Do() returned unexpected error: %v", err)
+	}
+	if _, _, err := client.Contact.ListAll(); err != nil {
+		t.Fatalf("recording Contact.ListAll() returned unexpected error: %v", err)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop() returned unexpected error: %v", err)
+	}
+	s.Close()
+
+	b, _ := ioutil.ReadFile(path)
+	for _, secret := range []string{"secret-token", "1BoatSLRHtKNngkdXEeobR76b53LETtpyT", "Jane", "jane@example.org"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("cassette contains unscrubbed value %q", secret)
+		}
+	}
+
+	rec, err = NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder() returned unexpected error: %v", err)
+	}
+	client = uphold.NewClient(rec.Client())
+	client.SetAPIURL(s.URL + APIPrefix)
+
+	replayed, _, err := client.Card.ListAll()
+	if err != nil {
+		t.Fatalf("replaying Card.ListAll() returned unexpected error: %v", err)
+	}
+	(*recorded)[0].Address["bitcoin"] = Redacted
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed %+v, want %+v", replayed, recorded)
+	}
+
+	contacts, _, err := client.Contact.ListAll()
+	if err != nil {
+		t.Fatalf("replaying Contact.ListAll() returned unexpected error: %v", err)
+	}
+	if got, want := (*contacts)[0].Emails[0], redactedEmail; got != want {
+		t.Errorf("replayed contact email is %v, want %v", got, want)
+	}
+
+	if _, _, err := client.Card.ListAll(); err == nil {
+		t.Error("expected error once the recorded interactions are used up")
+	}
+}
+
+func TestRecorderMatchBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upholdtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+
+	cassette := `{"interactions": [
+  {"request": {"method": "POST", "url": "http://example.com/v0/me/cards", "body": "{\"currency\":\"BTC\",\"label\":\"b\"}"},
+   "response": {"statusCode": 200, "body": "{\"id\":\"btc\"}"}},
+  {"request": {"method": "POST", "url": "http://example.com/v0/me/cards", "body": "{\"currency\":\"USD\",\"label\":\"u\"}"},
+   "response": {"statusCode": 200, "body": "{\"id\":\"usd\"}"}}
+]}`
+	if err := ioutil.WriteFile(path, []byte(cassette), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder() returned unexpected error: %v", err)
+	}
+	rec.MatchBody = true
+
+	client := uphold.NewClient(&http.Client{Transport: rec})
+	client.SetAPIURL("http://example.com/v0/")
+
+	card, _, err := client.Card.Add(uphold.Card{Label: "u", Currency: "USD"})
+	if err != nil {
+		t.Fatalf("Card.Add() returned unexpected error: %v", err)
+	}
+	if got, want := card.ID, "usd"; got != want {
+		t.Errorf("Card.Add() returned card %v, want %v", got, want)
+	}
+}
+
+func TestRecorderReplayIgnoresHost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upholdtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cassette.json")
+
+	cassette := `{"interactions": [
+  {"request": {"method": "GET", "url": "https://api-sandbox.uphold.com/v0/me/cards/c1?b=2&a=1"},
+   "response": {"statusCode": 200, "body": "{\"id\":\"c1\"}"}}
+]}`
+	if err := ioutil.WriteFile(path, []byte(cassette), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("NewRecorder() returned unexpected error: %v", err)
+	}
+
+	resp, err := rec.Client().Get("http://127.0.0.1:1/v0/me/cards/c1?a=1&b=2")
+	if err != nil {
+		t.Fatalf("Get() returned unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := rec.Client().Get("http://127.0.0.1:1/v0/me/cards/c1?a=1"); err == nil {
+		t.Error("expected an error for a request with another query")
+	}
+}
+
+func TestRecorderScrubsAddressesAndContacts(t *testing.T) {
+	body := scrubBody([]byte(`{"addresses":[{"id":"1BoatSLRHtKNngkdXEeobR76b53LETtpyT","network":"bitcoin"}],"email":"jane@example.org","password":"hunter2"}`))
+	for _, secret := range []string{"1BoatSLRHtKNngkdXEeobR76b53LETtpyT", "jane@example.org", "hunter2"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("scrubbed body %s contains %q", body, secret)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package upholdtest provides an in-memory fake of the Uphold API for
+// testing code that depends on the uphold client without network access.
+//
+// A Server keeps cards, contacts, accounts, tickers and transactions in
+// memory and implements the quote, commit and cancel lifecycle of
+// transactions, moving balances between cards as the real API would.
+// Deposits from SEPA and ACH accounts and withdrawals to them are
+// reported with the account type as origin or destination type.
+// Rate limits and arbitrary error responses can be injected to exercise
+// failure paths.
+package upholdtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gufran/uphold"
+)
+
+// APIPrefix is the path under which the fake API is served
+const APIPrefix = "/v0/"
+
+// Server is a stateful fake Uphold API server
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	id int
+
+	cards     map[string]*uphold.Card
+	cardOrder []string
+
+	contacts     map[string]*uphold.Contact
+	contactOrder []string
+
+	accounts     map[string]*uphold.Account
+	accountOrder []string
+
+	tickers []uphold.CurrencyPair
+
+	txns     map[string]*transaction
+	txnOrder []string
+
+	rateLimit   int
+	rateWindow  time.Duration
+	rateRemain  int
+	rateResetOn time.Time
+
+	faults []fault
+}
+
+// transaction is a stored transaction along
+// with the card it was created on
+type transaction struct {
+	cardID string
+	txn    uphold.Txn
+}
+
+// fault is an injected error response
+type fault struct {
+	method string
+	path   string
+	status int
+}
+
+// NewServer starts and returns a new fake server. The caller should
+// call Close when finished to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		cards:    map[string]*uphold.Card{},
+		contacts: map[string]*uphold.Contact{},
+		accounts: map[string]*uphold.Account{},
+		txns:     map[string]*transaction{},
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns an uphold client configured to talk to the server
+func (s *Server) Client() *uphold.Client {
+	c := uphold.NewClient(http.DefaultClient)
+	_ = c.SetAPIURL(s.URL + APIPrefix)
+	return c
+}
+
+// AddCard stores a card on the server and returns the stored copy.
+// An ID is generated if the card does not have one.
+func (s *Server) AddCard(c uphold.Card) uphold.Card {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.ID == "" {
+		c.ID = s.nextID()
+	}
+	if _, ok := s.cards[c.ID]; !ok {
+		s.cardOrder = append(s.cardOrder, c.ID)
+	}
+
+	s.cards[c.ID] = &c
+	return c
+}
+
+// Card returns the current state of the card with given ID
+func (s *Server) Card(ID string) (uphold.Card, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.cards[ID]
+	if !ok {
+		return uphold.Card{}, false
+	}
+	return *c, true
+}
+
+// AddContact stores a contact on the server and returns the stored copy.
+// An ID is generated if the contact does not have one.
+func (s *Server) AddContact(c uphold.Contact) uphold.Contact {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.ID == "" {
+		c.ID = s.nextID()
+	}
+	if _, ok := s.contacts[c.ID]; !ok {
+		s.contactOrder = append(s.contactOrder, c.ID)
+	}
+
+	s.contacts[c.ID] = &c
+	return c
+}
+
+// AddAccount stores an account on the server and returns the stored copy.
+// An ID is generated if the account does not have one.
+func (s *Server) AddAccount(a uphold.Account) uphold.Account {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a.ID == "" {
+		a.ID = s.nextID()
+	}
+	if _, ok := s.accounts[a.ID]; !ok {
+		s.accountOrder = append(s.accountOrder, a.ID)
+	}
+
+	s.accounts[a.ID] = &a
+	return a
+}
+
+// SetTickers replaces the tickers served by the server. The pairs
+// are also used to convert amounts between currencies.
+func (s *Server) SetTickers(pairs ...uphold.CurrencyPair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tickers = append([]uphold.CurrencyPair(nil), pairs...)
+}
+
+// Transaction returns the current state of the transaction with given ID
+func (s *Server) Transaction(ID string) (uphold.Txn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.txns[ID]
+	if !ok {
+		return uphold.Txn{}, false
+	}
+	return t.txn, true
+}
+
+// Transactions returns all the transactions in the order of creation
+func (s *Server) Transactions() []uphold.Txn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txns := make([]uphold.Txn, 0, len(s.txnOrder))
+	for _, id := range s.txnOrder {
+		txns = append(txns, s.txns[id].txn)
+	}
+	return txns
+}
+
+// SetRateLimit limits the server to serve limit requests per window.
+// Requests beyond the limit are answered with 429 Too Many Requests.
+// A limit of zero disables rate limiting.
+func (s *Server) SetRateLimit(limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rateLimit = limit
+	s.rateWindow = window
+	s.rateRemain = limit
+	s.rateResetOn = time.Now().Add(window)
+}
+
+// FailNext makes the next request matching method and path fail with
+// given status code. The path is relative to the API root, for example
+// "me/cards". An empty method matches any method.
+func (s *Server) FailNext(method, path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, fault{
+		method: method,
+		path:   strings.Trim(path, "/"),
+		status: status,
+	})
+}
+
+// nextID generates a new identifier shaped like the UUIDs used by Uphold.
+// It must be called with mu held.
+func (s *Server) nextID() string {
+	s.id++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", s.id)
+}
+
+// paidRate returns the amount of to paid for one unit of from, at the
+// rates of the configured tickers. A ticker pair quotes the price of its
+// base currency: buying it is done at Ask and selling it at Bid, the
+// house always being on the good side. It must be called with mu held.
+func (s *Server) paidRate(from, to string) (float32, bool) {
+	if from == to {
+		return 1, true
+	}
+
+	for _, p := range s.tickers {
+		switch p.Pair {
+		case from + to:
+			// buying from with to
+			return p.Ask, p.Ask != 0
+		case to + from:
+			// selling to for from
+			return 1 / p.Bid, p.Bid != 0
+		}
+	}
+	return 0, false
+}
+
+// receivedRate returns the amount of to received for one unit of from,
+// at the rates of the configured tickers. It must be called with mu held.
+func (s *Server) receivedRate(from, to string) (float32, bool) {
+	if from == to {
+		return 1, true
+	}
+
+	for _, p := range s.tickers {
+		switch p.Pair {
+		case from + to:
+			// selling from for to
+			return p.Bid, p.Bid != 0
+		case to + from:
+			// buying to with from
+			return 1 / p.Ask, p.Ask != 0
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,316 @@
+package upholdtest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gufran/uphold"
+)
+
+func TestServerCards(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.AddCard(uphold.Card{Label: "USD card", Currency: "USD", Balance: 10, Available: 10})
+
+	client := s.Client()
+	added, _, err := client.Card.Add(uphold.Card{Label: "BTC card", Currency: "BTC"})
+	if err != nil {
+		t.Fatalf("Card.Add() returned unexpected error: %v", err)
+	}
+
+	cards, _, err := client.Card.ListAll()
+	if err != nil {
+		t.Fatalf("Card.ListAll() returned unexpected error: %v", err)
+	}
+	if got, want := len(*cards), 2; got != want {
+		t.Fatalf("Card.ListAll() returned %d cards, want %d", got, want)
+	}
+	if got, want := (*cards)[1].ID, added.ID; got != want {
+		t.Errorf("Card.ListAll()[1].ID is %v, want %v", got, want)
+	}
+
+	_, _, err = client.Card.List("missing")
+	if e, ok := err.(uphold.ErrorResponse); !ok || e.Response.StatusCode != http.StatusNotFound {
+		t.Errorf("Card.List(missing) returned %v, want 404 error", err)
+	}
+}
+
+func TestServerSweepAndDelete(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	old := s.AddCard(uphold.Card{Label: "Old", Currency: "USD", Balance: 12.5, Available: 12.5})
+	dest := s.AddCard(uphold.Card{Label: "Main", Currency: "USD", Balance: 10, Available: 10})
+
+	client := s.Client()
+
+	// the API refuses to delete a card holding funds
+	req, err := client.NewRequest("DELETE", "me/cards/"+old.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req, nil); err == nil {
+		t.Error("deleting a card holding funds should fail")
+	}
+
+	txn, _, err := client.Card.SweepAndDelete(old, dest)
+	if err != nil {
+		t.Fatalf("Card.SweepAndDelete() returned unexpected error: %v", err)
+	}
+	if txn == nil || txn.Status != uphold.TxnStatusCompleted {
+		t.Errorf("Card.SweepAndDelete() returned %+v, want a completed transaction", txn)
+	}
+
+	if _, ok := s.Card(old.ID); ok {
+		t.Error("swept card was not deleted")
+	}
+	if c, _ := s.Card(dest.ID); c.Balance != 22.5 {
+		t.Errorf("destination balance is %v, want 22.5", c.Balance)
+	}
+	cards, _, err := client.Card.ListAll()
+	if err != nil || len(*cards) != 1 {
+		t.Errorf("Card.ListAll() returned %v, %v, want the destination card only", cards, err)
+	}
+}
+
+func TestServerTransfer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	usd := s.AddCard(uphold.Card{Label: "USD", Currency: "USD", Balance: 100, Available: 100})
+	btc := s.AddCard(uphold.Card{Label: "BTC", Currency: "BTC"})
+	s.SetTickers(uphold.CurrencyPair{Pair: "BTCUSD", Ask: 500, Bid: 400, Currency: "USD"})
+
+	client := s.Client()
+	q := uphold.Quote{
+		Denomination: &uphold.QuoteDenomination{Amount: 0.1, Currency: uphold.CurrencyBTC},
+		Destination:  btc.ID,
+	}
+
+	txn, _, err := client.Transaction.Create(usd, q)
+	if err != nil {
+		t.Fatalf("Transaction.Create() returned unexpected error: %v", err)
+	}
+	if got, want := txn.Status, uphold.TxnStatus(uphold.TxnStatusPending); got != want {
+		t.Errorf("Transaction.Create() status is %v, want %v", got, want)
+	}
+	if c, _ := s.Card(usd.ID); c.Balance != 100 {
+		t.Errorf("quote moved funds, balance is %v", c.Balance)
+	}
+
+	txn, _, err = client.Transaction.Commit(usd, *txn, "dca")
+	if err != nil {
+		t.Fatalf("Transaction.Commit() returned unexpected error: %v", err)
+	}
+	if got, want := txn.Status, uphold.TxnStatus(uphold.TxnStatusCompleted); got != want {
+		t.Errorf("Transaction.Commit() status is %v, want %v", got, want)
+	}
+	if c, _ := s.Card(usd.ID); c.Balance != 50 || c.Available != 50 {
+		t.Errorf("origin balance is %v/%v, want 50/50", c.Balance, c.Available)
+	}
+	if c, _ := s.Card(btc.ID); c.Balance != 0.1 {
+		t.Errorf("destination balance is %v, want 0.1", c.Balance)
+	}
+
+	if _, _, err = client.Transaction.Commit(usd, *txn, ""); err == nil {
+		t.Error("committing a completed transaction should fail")
+	}
+
+	q.Denomination.Amount = 1
+	if _, _, err = client.Transaction.Create(usd, q); err == nil {
+		t.Error("quote beyond available balance should fail")
+	}
+}
+
+func TestServerTransferSelfScope(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	usd := s.AddCard(uphold.Card{Label: "USD", Currency: "USD", Balance: 100, Available: 100})
+	savings := s.AddCard(uphold.Card{Label: "Savings", Currency: "USD"})
+
+	client := s.Client()
+	client.SetGrantedScopes([]uphold.Permission{uphold.PermissionTransactionsTransferSelf})
+
+	q := uphold.Quote{
+		Denomination: &uphold.QuoteDenomination{Amount: 40, Currency: uphold.CurrencyUSD},
+		Destination:  savings.ID,
+	}
+	txn, _, err := client.Transaction.Create(usd, q)
+	if err != nil {
+		t.Fatalf("Transaction.Create() between own cards returned unexpected error: %v", err)
+	}
+	if _, _, err := client.Transaction.Commit(usd, *txn, ""); err != nil {
+		t.Fatalf("Transaction.Commit() between own cards returned unexpected error: %v", err)
+	}
+	if c, _ := s.Card(savings.ID); c.Balance != 40 {
+		t.Errorf("destination balance is %v, want 40", c.Balance)
+	}
+}
+
+func TestServerQuoteRates(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	usd := s.AddCard(uphold.Card{Label: "USD", Currency: "USD", Balance: 1000, Available: 1000})
+	btc := s.AddCard(uphold.Card{Label: "BTC", Currency: "BTC", Balance: 1, Available: 1})
+	s.SetTickers(uphold.CurrencyPair{Pair: "BTCUSD", Ask: 500, Bid: 400, Currency: "USD"})
+
+	// BTC is bought at the ask price and sold at the bid price
+	tests := []struct {
+		from, to    uphold.Card
+		amount      float32
+		currency    uphold.CurrencyCode
+		origin      float32
+		destination float32
+	}{
+		{usd, btc, 100, uphold.CurrencyUSD, 100, 0.2},
+		{usd, btc, 0.1, uphold.CurrencyBTC, 50, 0.1},
+		{btc, usd, 100, uphold.CurrencyUSD, 0.25, 100},
+		{btc, usd, 0.1, uphold.CurrencyBTC, 0.1, 40},
+	}
+
+	client := s.Client()
+	for _, tt := range tests {
+		q := uphold.Quote{
+			Denomination: &uphold.QuoteDenomination{Amount: tt.amount, Currency: tt.currency},
+			Destination:  tt.to.ID,
+		}
+		txn, _, err := client.Transaction.Create(tt.from, q)
+		if err != nil {
+			t.Fatalf("Transaction.Create() returned unexpected error: %v", err)
+		}
+		if txn.Origin.Amount != tt.origin || txn.Destination.Amount != tt.destination {
+			t.Errorf("quote of %g %s from %s to %s takes %g and gives %g, want %g and %g",
+				tt.amount, tt.currency, tt.from.Currency, tt.to.Currency, txn.Origin.Amount, txn.Destination.Amount, tt.origin, tt.destination)
+		}
+	}
+}
+
+func TestServerBankTransfers(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	card := s.AddCard(uphold.Card{Label: "EUR", Currency: "EUR", Balance: 10, Available: 10})
+	bank := s.AddAccount(uphold.Account{Label: "Bank", Currency: "EUR", Status: uphold.AccountStatusOK, Type: uphold.AccountTypeSepa})
+
+	// deposits from bank accounts are not limited by the policy
+	client := s.Client()
+	client.SetPolicy(&uphold.Policy{MaxAmount: map[uphold.CurrencyCode]float32{uphold.CurrencyEUR: 50}})
+
+	txn, _, err := client.Account.Deposit(bank, card, 100, true)
+	if err != nil {
+		t.Fatalf("Account.Deposit() returned unexpected error: %v", err)
+	}
+	if txn.Status != uphold.TxnStatusCompleted || txn.Origin.Type != uphold.AccountTypeSepa {
+		t.Errorf("Account.Deposit() returned %+v, want a completed deposit from a SEPA account", txn)
+	}
+	if c, _ := s.Card(card.ID); c.Balance != 110 {
+		t.Errorf("card balance is %v, want 110", c.Balance)
+	}
+
+	if _, _, err := client.Account.Withdraw(card, bank, 100, true); err == nil {
+		t.Error("Account.Withdraw() beyond the policy should fail")
+	}
+	txn, _, err = client.Account.Withdraw(card, bank, 40, true)
+	if err != nil {
+		t.Fatalf("Account.Withdraw() returned unexpected error: %v", err)
+	}
+	if txn.Destination.Type != uphold.AccountTypeSepa {
+		t.Errorf("Account.Withdraw() returned %+v, want a withdrawal to a SEPA account", txn)
+	}
+	if c, _ := s.Card(card.ID); c.Balance != 70 {
+		t.Errorf("card balance is %v, want 70", c.Balance)
+	}
+}
+
+func TestServerCancelUnclaimed(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	usd := s.AddCard(uphold.Card{Label: "USD", Currency: "USD", Balance: 20, Available: 20})
+	client := s.Client()
+
+	q := uphold.Quote{
+		Denomination: &uphold.QuoteDenomination{Amount: 5, Currency: uphold.CurrencyUSD},
+		Destination:  "friend@example.com",
+		Realtime:     true,
+	}
+	txn, _, err := client.Transaction.Create(usd, q)
+	if err != nil {
+		t.Fatalf("Transaction.Create() returned unexpected error: %v", err)
+	}
+	if got, want := txn.Status, uphold.TxnStatus(uphold.TxnStatusWaiting); got != want {
+		t.Fatalf("Transaction.Create() status is %v, want %v", got, want)
+	}
+	if c, _ := s.Card(usd.ID); c.Balance != 15 {
+		t.Errorf("balance after send is %v, want 15", c.Balance)
+	}
+
+	if _, _, err := client.Transaction.Resend(usd, *txn); err != nil {
+		t.Errorf("Transaction.Resend() returned unexpected error: %v", err)
+	}
+
+	txn, _, err = client.Transaction.Cancel(usd, *txn)
+	if err != nil {
+		t.Fatalf("Transaction.Cancel() returned unexpected error: %v", err)
+	}
+	if got, want := txn.Status, uphold.TxnStatus(uphold.TxnStatusCancelled); got != want {
+		t.Errorf("Transaction.Cancel() status is %v, want %v", got, want)
+	}
+	if c, _ := s.Card(usd.ID); c.Balance != 20 {
+		t.Errorf("balance after cancel is %v, want 20", c.Balance)
+	}
+
+	txns, _, err := client.Transaction.ListForCard(usd)
+	if err != nil {
+		t.Fatalf("Transaction.ListForCard() returned unexpected error: %v", err)
+	}
+	if got, want := len(*txns), 1; got != want {
+		t.Errorf("Transaction.ListForCard() returned %d transactions, want %d", got, want)
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.SetRateLimit(1, time.Minute)
+	client := s.Client()
+
+	if _, _, err := client.Ticker.ListAll(); err != nil {
+		t.Fatalf("Ticker.ListAll() returned unexpected error: %v", err)
+	}
+	if got, want := client.Rate().Remaining, 0; got != want {
+		t.Errorf("Client rate remaining = %v, want %v", got, want)
+	}
+
+	_, _, err := client.Ticker.ListAll()
+	if _, ok := err.(uphold.RateLimitError); !ok {
+		t.Errorf("Expected a RateLimitError, got %#v", err)
+	}
+}
+
+func TestServerFailNext(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.AddAccount(uphold.Account{Label: "bank", Currency: "EUR", Type: uphold.AccountTypeSepa})
+	s.FailNext("GET", "me/accounts", http.StatusInternalServerError)
+	client := s.Client()
+
+	_, _, err := client.Account.ListAll()
+	if e, ok := err.(uphold.ErrorResponse); !ok || e.Response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Account.ListAll() returned %v, want injected 500", err)
+	}
+
+	accounts, _, err := client.Account.ListAll()
+	if err != nil {
+		t.Fatalf("Account.ListAll() returned unexpected error: %v", err)
+	}
+	if got, want := len(*accounts), 1; got != want {
+		t.Errorf("Account.ListAll() returned %d accounts, want %d", got, want)
+	}
+}
@@ -0,0 +1,52 @@
+package uphold
+
+// Withdrawal sends funds of a card to an address on a blockchain
+type Withdrawal struct {
+	Network Network
+	Address string
+
+	// Memo is the destination tag of XRP withdrawals,
+	// required by most exchanges
+	Memo string
+
+	// Amount and Currency of the withdrawal, the currency of
+	// the card if empty
+	Amount   float32
+	Currency CurrencyCode
+
+	// Realtime commits the withdrawal as soon as it is quoted
+	Realtime bool
+}
+
+// Validate checks the address and the memo of the withdrawal offline
+func (w Withdrawal) Validate() error {
+	if err := ValidateAddress(w.Network, w.Address); err != nil {
+		return err
+	}
+	return ValidateMemo(w.Network, w.Memo)
+}
+
+// destinationTagSeparator separates an XRP address
+// from its destination tag in the destination of a quote
+const destinationTagSeparator = "?dt="
+
+// quote returns the quote of the withdrawal from card. The destination
+// tag of XRP withdrawals is appended to the address.
+func (w Withdrawal) quote(card Card) Quote {
+	currency := w.Currency
+	if currency == "" {
+		currency = CurrencyCode(card.Currency)
+	}
+
+	dest := w.Address
+	if w.Network == NetworkXRP && w.Memo != "" {
+		dest += destinationTagSeparator + w.Memo
+	}
+
+	return Quote{
+		Denomination: &QuoteDenomination{Amount: w.Amount, Currency: currency},
+		Destination:  dest,
+		Network:      w.Network,
+		Realtime:     w.Realtime,
+	}
+}
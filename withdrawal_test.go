@@ -0,0 +1,42 @@
+package uphold
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestTransactionsWithdraw(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testBody(t, r, `{"denomination":{"amount":"25","currency":"XRP"},"destination":"rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh?dt=12345","network":"xrp-ledger"}`)
+		fmt.Fprint(w, `{"id":"t1","status":"pending"}`)
+	})
+
+	w := Withdrawal{Network: NetworkXRP, Address: "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh", Memo: "12345", Amount: 25}
+	txn, _, err := client.Transaction.Withdraw(Card{ID: "c1", Currency: "XRP"}, w)
+	if err != nil {
+		t.Fatalf("Withdraw() returned unexpected error: %v", err)
+	}
+	if txn.ID != "t1" {
+		t.Errorf("Withdraw() returned %+v", txn)
+	}
+}
+
+func TestTransactionsWithdrawInvalidAddress(t *testing.T) {
+	setup()
+	defer teardown()
+
+	mux.HandleFunc("/me/cards/c1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("invalid withdrawal was quoted")
+	})
+
+	w := Withdrawal{Network: NetworkEthereum, Address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", Amount: 1}
+	_, _, err := client.Transaction.Withdraw(Card{ID: "c1", Currency: "ETH"}, w)
+	if e, ok := err.(AddressError); !ok || e.Network != NetworkEthereum {
+		t.Errorf("Withdraw() returned %v, want an AddressError", err)
+	}
+}